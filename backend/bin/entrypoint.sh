@@ -18,6 +18,10 @@ mysql -h $MYSQL_HOST -u root -p"$MYSQL_ROOT_PASSWORD" -e "CREATE DATABASE IF NOT
 echo "Applying schema..."
 mysql -h $MYSQL_HOST -u root -p"$MYSQL_ROOT_PASSWORD" $MYSQL_DATABASE < /app/internal/store/schema.sql
 
+# Add the columns introduced since the tables were created (idempotent operation)
+echo "Migrating schema..."
+go run ./cmd/migrate
+
 echo "Database initialization completed."
 
 # Start the main application
@@ -1,7 +1,7 @@
 #!/bin/sh
 
 echo "Running CSV downloader..."
-go run ./cmd/csvdownloader/main.go
+go run ./cmd/csvdownloader
 
 echo "Running CSV-to-MySQL processor..."
-go run ./cmd/csvtomysql/main.go
+go run ./cmd/csvtomysql
@@ -3,12 +3,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"os/exec"
+	"os/signal"
+	"syscall"
 
-	"github.com/chromedp/chromedp"
 	"github.com/computers33333/airaccidentdata/internal/config"
+	"github.com/computers33333/airaccidentdata/internal/downloader"
 )
 
 // main is the entry point of the application. It loads environment variables from a .env file,
@@ -16,45 +18,29 @@ import (
 func main() {
 	cfg := config.NewConfig()
 
-	downloadLink, err := fetchCsvDownloadLink(cfg.PageURL)
+	// The session client keeps the APEX session from the download page for the download itself
+	d := &downloader.Downloader{Client: downloader.NewSessionClient(0)}
+	output := flag.String("output", cfg.CSVFilePath, "path to save the CSV file to")
+	discovery := flag.String("discovery", "auto", "how to find the CSV link on the FAA page: html, chromedp, or auto to parse the HTML and fall back to chromedp")
+	d.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	downloadLink, err := downloader.FindLink(ctx, *discovery, d.Client, cfg.PageURL)
 	if err != nil {
 		log.Fatalf("Error fetching CSV download link: %v", err)
 	}
 
-	if err := downloadCsvFile(downloadLink, cfg.CSVFilePath); err != nil {
+	result, err := d.Download(ctx, downloadLink, *output)
+	if err != nil {
 		log.Fatalf("Error downloading CSV file: %v", err)
 	}
 
-	fmt.Println("CSV file downloaded successfully")
-}
-
-// fetchCsvDownloadLink fetches the CSV download link from a given URL using chromedp.
-func fetchCsvDownloadLink(url string) (string, error) {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-
-	var downloadLink string
-	if err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.WaitVisible(`#div_wid a.bodytextlink`),
-		chromedp.Evaluate(`document.querySelector('#div_wid a.bodytextlink').href`, &downloadLink),
-	); err != nil {
-		return "", err
-	}
-
-	if downloadLink == "" {
-		return "", fmt.Errorf("CSV download link not found")
-	}
-
-	return downloadLink, nil
-}
-
-// downloadCsvFile downloads a CSV file using the `curl` shell command.
-func downloadCsvFile(downloadLink, outputPath string) error {
-	cmd := exec.Command("curl", "-o", outputPath, downloadLink)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error downloading CSV file: %v, output: %s", err, output)
+	if !result.Changed {
+		fmt.Printf("CSV file unchanged since the last download (%s, sha256 %s)\n", downloader.FormatSize(result.Size), result.SHA256)
+		return
 	}
-	return nil
+	fmt.Printf("CSV file downloaded successfully (%s, sha256 %s)\n", downloader.FormatSize(result.Size), result.SHA256)
 }
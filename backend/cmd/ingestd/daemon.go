@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/downloader"
+	"github.com/computers33333/airaccidentdata/internal/importer"
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// lockName is the advisory lock held while ingesting, so that a single instance imports at a time.
+const lockName = "airaccidentdata-ingest"
+
+// Outcomes of an ingestion attempt.
+const (
+	outcomeRunning     = "running"
+	outcomeSucceeded   = "succeeded"
+	outcomeSkipped     = "skipped" // The file was already imported
+	outcomeLocked      = "locked"  // Another instance was ingesting
+	outcomeFailed      = "failed"
+	outcomeInterrupted = "interrupted"
+)
+
+// attempt is a scheduled ingestion by this instance.
+type attempt struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Outcome    string     `json:"outcome"`
+	RunID      *int       `json:"run_id,omitempty"` // Ingestion run recorded for the attempt, or that already imported the file
+	Error      string     `json:"error,omitempty"`
+}
+
+// daemon downloads and imports the FAA data on a schedule.
+type daemon struct {
+	db           *store.DB
+	schedule     schedule
+	scheduleSpec string
+	pageURL      string // FAA page linking to the CSV file
+	discovery    string // Strategy used to find the link, see downloader.FindLink
+	path         string // Where the CSV file is downloaded to
+	downloader   *downloader.Downloader
+	importConfig importer.Config
+
+	mu      sync.Mutex
+	last    *attempt
+	nextRun time.Time
+}
+
+// run ingests at every scheduled time until ctx is cancelled, first ingesting right away if now is set.
+func (d *daemon) run(ctx context.Context, now bool) {
+	if now {
+		d.ingest(ctx)
+	}
+	for {
+		next := d.schedule.Next(time.Now())
+		d.mu.Lock()
+		d.nextRun = next
+		d.mu.Unlock()
+		log.Printf("Next ingestion at %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.ingest(ctx)
+	}
+}
+
+// ingest downloads the CSV file and imports it if it has not been imported yet, holding the ingestion lock.
+// Cancelling ctx stops the download, or stops reading the file and lets the records already read be written.
+func (d *daemon) ingest(ctx context.Context) *attempt {
+	a := &attempt{StartedAt: time.Now().UTC().Truncate(time.Second), Outcome: outcomeRunning}
+	d.setAttempt(a)
+	outcome, runID, err := d.ingestLocked(ctx)
+
+	finishedAt := time.Now().UTC().Truncate(time.Second)
+	done := &attempt{StartedAt: a.StartedAt, FinishedAt: &finishedAt, Outcome: outcome, RunID: runID}
+	if err != nil {
+		done.Error = err.Error()
+		log.Printf("Ingestion %s: %v", outcome, err)
+	} else {
+		log.Printf("Ingestion %s", outcome)
+	}
+	d.setAttempt(done)
+	return done
+}
+
+// ingestLocked runs an ingestion under the lock, returning its outcome and the ingestion run it relates to.
+func (d *daemon) ingestLocked(ctx context.Context) (string, *int, error) {
+	lock, err := store.TryAdvisoryLock(ctx, d.db, lockName)
+	if err != nil {
+		return outcomeFailed, nil, err
+	}
+	if lock == nil {
+		return outcomeLocked, nil, nil
+	}
+	defer func() {
+		if err := lock.Release(); err != nil {
+			log.Printf("Failed to release ingestion lock: %v", err)
+		}
+	}()
+
+	link, err := downloader.FindLink(ctx, d.discovery, d.downloader.Client, d.pageURL)
+	if err == nil {
+		var download *downloader.Result
+		download, err = d.downloader.Download(ctx, link, d.path)
+		if err == nil {
+			log.Printf("Downloaded %s, sha256 %s", downloader.FormatSize(download.Size), download.SHA256)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeInterrupted, nil, err
+		}
+		runID := d.recordFailure(link, fmt.Errorf("download failed: %w", err))
+		return outcomeFailed, runID, fmt.Errorf("download failed: %w", err)
+	}
+
+	// Unchanged downloads are still passed to the importer, which skips files that were imported successfully
+	// and retries files whose import failed.
+	cfg := d.importConfig
+	cfg.SourceURL = link
+	result, err := importer.Run(ctx, d.db, importer.FileSource(d.path), cfg)
+	var runID *int
+	if result != nil && result.Run != nil {
+		runID = &result.Run.ID
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return outcomeInterrupted, runID, err
+	case err != nil:
+		if runID == nil {
+			runID = d.recordFailure(link, err)
+		}
+		return outcomeFailed, runID, err
+	case result.Skipped != nil:
+		return outcomeSkipped, &result.Skipped.ID, nil
+	default:
+		return outcomeSucceeded, runID, nil
+	}
+}
+
+// recordFailure records an ingestion that failed before the importer recorded a run, so every scheduled
+// ingestion appears in the run history.
+func (d *daemon) recordFailure(sourceURL string, err error) *int {
+	if sourceURL == "" {
+		sourceURL = d.pageURL
+	}
+	run := &models.IngestionRun{SourceURL: sourceURL, FileName: filepath.Base(d.path)}
+	if err := store.StartIngestionRun(context.Background(), d.db, run); err != nil {
+		log.Printf("Failed to record ingestion run: %v", err)
+		return nil
+	}
+	run.Status = store.IngestionFailed
+	run.Error = err.Error()
+	if err := store.FinishIngestionRun(context.Background(), d.db, run); err != nil {
+		log.Printf("Failed to record ingestion run: %v", err)
+	}
+	return &run.ID
+}
+
+// setAttempt replaces the attempt reported by the health endpoint.
+func (d *daemon) setAttempt(a *attempt) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = a
+}
+
+// healthResponse is the status reported by the health endpoint.
+type healthResponse struct {
+	Status      string               `json:"status"` // ok, or failing when the last attempt failed or the database is unreachable
+	Schedule    string               `json:"schedule"`
+	NextRun     *time.Time           `json:"next_run,omitempty"`
+	LastAttempt *attempt             `json:"last_attempt,omitempty"` // Last ingestion attempted by this instance
+	LastRun     *models.IngestionRun `json:"last_run,omitempty"`     // Last ingestion run recorded by any instance
+	Error       string               `json:"error,omitempty"`
+}
+
+// healthHandler reports the last ingestion attempt and run, responding 503 when the last attempt failed or the
+// run history cannot be read.
+func (d *daemon) healthHandler(c *gin.Context) {
+	d.mu.Lock()
+	response := healthResponse{Status: "ok", Schedule: d.scheduleSpec, LastAttempt: d.last}
+	if !d.nextRun.IsZero() {
+		nextRun := d.nextRun
+		response.NextRun = &nextRun
+	}
+	d.mu.Unlock()
+
+	status := http.StatusOK
+	lastRun, err := store.LastIngestionRun(c.Request.Context(), d.db)
+	if err != nil {
+		response.Status, response.Error = "failing", err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	response.LastRun = lastRun
+	if response.LastAttempt != nil && response.LastAttempt.Outcome == outcomeFailed {
+		response.Status = "failing"
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,135 @@
+// Daemon tests run scheduled ingestions against an httptest server standing in for the FAA site and a
+// temporary SQLite database.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/downloader"
+	"github.com/computers33333/airaccidentdata/internal/importer"
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestDaemon returns a daemon ingesting from a test FAA site into a fresh SQLite database. The site
+// links to testdata/faa.csv, or answers 404 for the file when missing is set.
+func newTestDaemon(t *testing.T, missing *bool) *daemon {
+	t.Helper()
+
+	csv, err := os.ReadFile(filepath.Join("testdata", "faa.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/page":
+			w.Write([]byte(`<html><body><div id="div_wid"><a class="bodytextlink" href="/faa.csv">CSV</a></div></body></html>`))
+		case r.URL.Path == "/faa.csv" && !*missing:
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write(csv)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	db, err := store.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &daemon{
+		db:           db,
+		schedule:     interval(time.Hour),
+		scheduleSpec: "@every 1h",
+		pageURL:      server.URL + "/page",
+		discovery:    "html",
+		path:         filepath.Join(t.TempDir(), "faa.csv"),
+		downloader:   &downloader.Downloader{Client: downloader.NewSessionClient(0), Attempts: 1, MinSize: 10, MaxSize: 1 << 20},
+		importConfig: importer.Config{BatchSize: 10, Geocoder: "gazetteer", GazetteerPaths: []string{"testdata/geonames.txt"}, MaxRejected: 0.05},
+	}
+}
+
+// health fetches the daemon's health endpoint.
+func health(t *testing.T, d *daemon) (int, healthResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health", d.healthHandler)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var response healthResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return recorder.Code, response
+}
+
+// TestDaemon_Ingest tests that an ingestion imports the file once, skips it while it is unchanged, and is
+// reported by the health endpoint.
+func TestDaemon_Ingest(t *testing.T) {
+	missing := false
+	d := newTestDaemon(t, &missing)
+
+	if a := d.ingest(context.Background()); a.Outcome != outcomeSucceeded || a.RunID == nil || *a.RunID != 1 {
+		t.Fatalf("Expected run 1 to succeed, got %+v", a)
+	}
+	if a := d.ingest(context.Background()); a.Outcome != outcomeSkipped || a.RunID == nil || *a.RunID != 1 {
+		t.Errorf("Expected the unchanged file to be skipped as imported by run 1, got %+v", a)
+	}
+
+	code, response := health(t, d)
+	if code != http.StatusOK || response.Status != "ok" || response.LastAttempt.Outcome != outcomeSkipped {
+		t.Errorf("Expected an ok status after a skipped attempt, got %d %+v", code, response)
+	}
+	if response.LastRun == nil || response.LastRun.ID != 1 || response.LastRun.RowsInserted != 2 || response.LastRun.Status != store.IngestionSucceeded {
+		t.Errorf("Expected run 1 inserting 2 records, got %+v", response.LastRun)
+	}
+}
+
+// TestDaemon_Failed tests that a failed download is recorded as a run and reported as failing.
+func TestDaemon_Failed(t *testing.T) {
+	missing := true
+	d := newTestDaemon(t, &missing)
+
+	if a := d.ingest(context.Background()); a.Outcome != outcomeFailed || a.RunID == nil || a.Error == "" {
+		t.Fatalf("Expected a recorded failure, got %+v", a)
+	}
+
+	code, response := health(t, d)
+	if code != http.StatusServiceUnavailable || response.Status != "failing" {
+		t.Errorf("Expected a failing status, got %d %+v", code, response)
+	}
+	if response.LastRun == nil || response.LastRun.Status != store.IngestionFailed || response.LastRun.Error == "" {
+		t.Errorf("Expected the failed run in the history, got %+v", response.LastRun)
+	}
+}
+
+// TestDaemon_Locked tests that an instance does not ingest while another one holds the lock.
+func TestDaemon_Locked(t *testing.T) {
+	missing := false
+	d := newTestDaemon(t, &missing)
+
+	lock, err := store.TryAdvisoryLock(context.Background(), d.db, lockName)
+	if err != nil || lock == nil {
+		t.Fatalf("Failed to take the lock: %v", err)
+	}
+	defer lock.Release()
+
+	if a := d.ingest(context.Background()); a.Outcome != outcomeLocked {
+		t.Errorf("Expected the ingestion to be skipped while locked, got %+v", a)
+	}
+	if _, err := os.Stat(d.path); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be downloaded, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package main runs a daemon that downloads the FAA accident and incident data and imports it on a schedule,
+// reporting the last ingestion on a health endpoint.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/config"
+	"github.com/computers33333/airaccidentdata/internal/downloader"
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// main is the entry point of the daemon. It ingests at every scheduled time until interrupted; an ingestion in
+// progress then stops reading the file and writes the records already read before the daemon exits.
+func main() {
+	cfg := config.NewConfig()
+
+	d := &daemon{
+		pageURL:    cfg.PageURL,
+		downloader: &downloader.Downloader{Client: downloader.NewSessionClient(0)},
+	}
+	flag.StringVar(&d.scheduleSpec, "schedule", "@daily", "when to ingest: a five-field cron expression, a macro such as @daily, or @every <duration>")
+	runNow := flag.Bool("run-now", true, "ingest once at startup before following the schedule")
+	addr := flag.String("addr", "0.0.0.0:8081", "address the health endpoint listens on")
+	flag.StringVar(&d.path, "output", cfg.CSVFilePath, "path to save the CSV file to")
+	flag.StringVar(&d.discovery, "discovery", "auto", "how to find the CSV link on the FAA page: html, chromedp, or auto to parse the HTML and fall back to chromedp")
+	d.downloader.RegisterFlags(flag.CommandLine)
+	d.importConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	var err error
+	d.schedule, err = parseSchedule(d.scheduleSpec)
+	if err != nil {
+		log.Fatalf("Invalid schedule: %v", err)
+	}
+	d.importConfig.GoogleMapsAPIKey = cfg.GoogleMapsAPIKey
+
+	d.db, err = store.OpenDB(cfg.DataSourceName)
+	if err != nil {
+		log.Fatalf("Database setup failed: %v", err)
+	}
+	defer d.db.Close()
+
+	// Serve the health endpoint
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/health", d.healthHandler)
+	server := &http.Server{Addr: *addr, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Health endpoint failed: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Ingesting on schedule %q, health endpoint on %s", d.scheduleSpec, *addr)
+	d.run(ctx, *runNow)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down health endpoint: %v", err)
+	}
+	log.Println("Ingestion daemon stopped")
+}
@@ -0,0 +1,49 @@
+// Schedule tests compute the next run of cron expressions from fixed times.
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseSchedule tests the next time several schedules fire after Wednesday 2023-01-04 10:30 UTC.
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2023, 1, 4, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"@daily", "2023-01-05 00:00"},
+		{"@hourly", "2023-01-04 11:00"},
+		{"@every 6h", "2023-01-04 16:30"},
+		{"30 10 * * *", "2023-01-05 10:30"}, // Strictly after from
+		{"*/15 * * * *", "2023-01-04 10:45"},
+		{"0 6,18 * * *", "2023-01-04 18:00"},
+		{"0 6 * * 1-5", "2023-01-05 06:00"},
+		{"0 6 * * 7", "2023-01-08 06:00"}, // 7 is Sunday
+		{"0 0 1 */3 *", "2023-04-01 00:00"},
+		{"0 0 15 * 1", "2023-01-09 00:00"}, // Both day fields restricted: the 15th or a Monday
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+	}
+
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from).Format("2006-01-02 15:04"); got != tt.expected {
+			t.Errorf("Expected %q to fire at %s, got %s", tt.spec, tt.expected, got)
+		}
+	}
+}
+
+// TestParseSchedule_Invalid tests that malformed schedules are rejected.
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "@often", "@every 10s", "0 6 * *", "60 * * * *", "0 0 31 2 *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
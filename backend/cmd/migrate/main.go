@@ -0,0 +1,29 @@
+// Package main provides functionality to add the columns introduced since the schema was first applied to an
+// existing MySQL, PostgreSQL or SQLite database.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/computers33333/airaccidentdata/internal/config"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// main is the entry point of the application. It is safe to run on every start, as applied migrations are skipped.
+func main() {
+	// Load configuration
+	appConfig := config.NewConfig()
+
+	// Initialize the database
+	db, err := store.OpenDB(appConfig.DataSourceName)
+	if err != nil {
+		log.Fatalf("Database setup failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := store.Migrate(context.Background(), db); err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	log.Println("Database migrated successfully.")
+}
@@ -0,0 +1,75 @@
+// Package main provides functionality to import the NTSB aviation accident database into a MySQL, PostgreSQL or
+// SQLite database next to the FAA data.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/computers33333/airaccidentdata/internal/config"
+	"github.com/computers33333/airaccidentdata/internal/importer"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// ntsbSourceURL is the page the NTSB database is downloaded from, recorded with the ingestion run by default.
+const ntsbSourceURL = "https://data.ntsb.gov/avdata"
+
+// main is the entry point of the application. Its argument is a directory holding the events, aircraft, injury and
+// narratives tables of the NTSB database exported as CSV files.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] directory\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var cfg importer.Config
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.BoolVar(&cfg.Force, "force", false, "import the tables even if they were already imported successfully")
+	flag.StringVar(&cfg.SourceURL, "source-url", ntsbSourceURL, "URL the NTSB database was downloaded from, recorded with the ingestion run")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	appConfig := config.NewConfig()
+	cfg.GoogleMapsAPIKey = appConfig.GoogleMapsAPIKey
+
+	// Initialize the database
+	db, err := store.OpenDB(appConfig.DataSourceName)
+	if err != nil {
+		log.Fatalf("Database setup failed: %v", err)
+	}
+	defer db.Close()
+
+	// Stop reading on the first interrupt and let the records in flight be written, as csvtomysql does.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		log.Println("Interrupted, finishing the records already read; interrupt again to abort")
+		cancel()
+	}()
+
+	result, err := importer.RunNTSB(ctx, db, flag.Arg(0), cfg)
+	if errors.Is(err, context.Canceled) {
+		log.Fatalf("Import interrupted; re-run it to import the remaining records")
+	}
+	if err != nil {
+		log.Fatalf("Failed to import NTSB data: %v", err)
+	}
+	if result.Skipped != nil {
+		log.Println("Use -force to import the tables again.")
+	}
+
+	log.Println("NTSB import completed successfully.")
+}
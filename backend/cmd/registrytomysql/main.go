@@ -0,0 +1,74 @@
+// Package main provides functionality to enrich the aircraft in a MySQL, PostgreSQL or SQLite database with the FAA
+// releasable aircraft registry.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/computers33333/airaccidentdata/internal/config"
+	"github.com/computers33333/airaccidentdata/internal/importer"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// registrySourceURL is where the registry is downloaded from, recorded with the ingestion run by default.
+const registrySourceURL = "https://registry.faa.gov/database/ReleasableAircraft.zip"
+
+// main is the entry point of the application. Its argument is a directory holding the MASTER.txt, ACFTREF.txt and
+// ENGINE.txt files extracted from ReleasableAircraft.zip.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] directory\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var cfg importer.Config
+	flag.IntVar(&cfg.BatchSize, "batch-size", 100, "number of aircraft updated per transaction")
+	flag.BoolVar(&cfg.Force, "force", false, "import the registry even if it was already imported successfully")
+	flag.StringVar(&cfg.SourceURL, "source-url", registrySourceURL, "URL the registry was downloaded from, recorded with the ingestion run")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	appConfig := config.NewConfig()
+
+	// Initialize the database
+	db, err := store.OpenDB(appConfig.DataSourceName)
+	if err != nil {
+		log.Fatalf("Database setup failed: %v", err)
+	}
+	defer db.Close()
+
+	// Stop on the first interrupt once the current transaction is committed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		log.Println("Interrupted, finishing the current batch; interrupt again to abort")
+		cancel()
+	}()
+
+	result, err := importer.RunRegistry(ctx, db, flag.Arg(0), cfg)
+	if errors.Is(err, context.Canceled) {
+		log.Fatalf("Import interrupted; re-run it with -force to update the remaining aircraft")
+	}
+	if err != nil {
+		log.Fatalf("Failed to import the aircraft registry: %v", err)
+	}
+	if result.Skipped != nil {
+		log.Println("Use -force to import the registry again, e.g. after importing new accidents.")
+	}
+
+	log.Println("Aircraft registry import completed successfully.")
+}
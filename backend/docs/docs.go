@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/accidents": {
             "get": {
-                "description": "Get a list of all aviation accidents with pagination.",
+                "description": "Get a list of aviation accidents with page or cursor pagination. All supplied filters are combined with AND.\nThe total is omitted when a cursor is given.",
                 "produces": [
                     "application/json"
                 ],
@@ -34,9 +34,107 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "description": "Number of accidents per page",
+                        "description": "Number of accidents per page, values above 100 are lowered to 100",
                         "name": "limit",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from next_cursor of a previous page, takes precedence over page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest event local date (YYYY-MM-DD)",
+                        "name": "date_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest event local date (YYYY-MM-DD)",
+                        "name": "date_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "Yes",
+                            "No"
+                        ],
+                        "type": "string",
+                        "description": "Fatal flag",
+                        "name": "fatal_flag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Flight phase",
+                        "name": "flight_phase",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "FAR part",
+                        "name": "far_part",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Event type description",
+                        "name": "event_type_description",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Aircraft damage description",
+                        "name": "aircraft_damage_description",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "State name of the accident location",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Aircraft make name",
+                        "name": "make",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Aircraft model name",
+                        "name": "model",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "FAA",
+                            "NTSB"
+                        ],
+                        "type": "string",
+                        "description": "Dataset the accident was imported from",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Aircraft registration, such as N12345, 12345 or n-12345",
+                        "name": "registration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. -event_local_date,id)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated related records to embed: aircraft, location, injuries, images",
+                        "name": "expand",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -57,6 +155,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -78,6 +188,12 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated related records to embed: aircraft, location, injuries, images",
+                        "name": "expand",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -104,6 +220,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -154,6 +282,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -201,13 +341,25 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/aircrafts": {
             "get": {
-                "description": "Retrieve a list of all aircrafts with pagination.",
+                "description": "Retrieve a list of all aircrafts with page or cursor pagination. The total is omitted when a cursor is given.",
                 "produces": [
                     "application/json"
                 ],
@@ -224,9 +376,21 @@ const docTemplate = `{
                     },
                     {
                         "type": "integer",
-                        "description": "Number of aircraft per page",
+                        "description": "Number of aircraft per page, values above 100 are lowered to 100",
                         "name": "limit",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from next_cursor of a previous page, takes precedence over page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated sort fields, prefix with - for descending (e.g. aircraft_make_name,-id)",
+                        "name": "sort",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -247,13 +411,84 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/aircrafts/registration/{reg}": {
+            "get": {
+                "description": "Retrieve every aircraft recorded with a registration, written as N12345, 12345 or n-12345, and all of their accidents oldest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Aircrafts"
+                ],
+                "summary": "Get an aircraft and its accidents by registration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Aircraft registration",
+                        "name": "reg",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Aircraft and accident history",
+                        "schema": {
+                            "$ref": "#/definitions/models.AircraftRegistrationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid registration",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Aircraft not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/aircrafts/{id}": {
             "get": {
-                "description": "Retrieve details of an aircraft by its ID",
+                "description": "Retrieve details of an aircraft by its ID, with its FAA registry details when known",
                 "produces": [
                     "application/json"
                 ],
@@ -294,6 +529,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -344,6 +591,76 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ingestions": {
+            "get": {
+                "description": "Retrieve the runs that imported FAA accident data, most recent first, with the source file and row counts of each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ingestions"
+                ],
+                "summary": "Get a list of ingestion runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of runs per page, values above 100 are lowered to 100",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ingestion runs with pagination details",
+                        "schema": {
+                            "$ref": "#/definitions/models.IngestionRunPaginatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "504": {
+                        "description": "Database query timed out",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -353,6 +670,14 @@ const docTemplate = `{
         "models.Accident": {
             "type": "object",
             "properties": {
+                "aircraft": {
+                    "description": "Related records, only populated when requested with the expand parameter.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Aircraft"
+                        }
+                    ]
+                },
                 "aircraft_damage_description": {
                     "type": "string"
                 },
@@ -395,12 +720,41 @@ const docTemplate = `{
                 "id": {
                     "type": "integer"
                 },
+                "ingested_by": {
+                    "description": "ID of the ingestion run that created or last updated the accident",
+                    "type": "integer"
+                },
+                "injuries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Injury"
+                    }
+                },
+                "linked_accident_id": {
+                    "description": "FAA accident of the same aircraft on the same date, for NTSB accidents",
+                    "type": "integer"
+                },
+                "location": {
+                    "$ref": "#/definitions/models.Location"
+                },
                 "location_id": {
                     "type": "integer"
                 },
                 "remark_text": {
                     "type": "string"
                 },
+                "source": {
+                    "description": "Dataset the accident was imported from",
+                    "type": "string",
+                    "enum": [
+                        "FAA",
+                        "NTSB"
+                    ]
+                },
+                "source_id": {
+                    "description": "NTSB event ID and aircraft key, empty for FAA accidents",
+                    "type": "string"
+                },
                 "updated": {
                     "type": "string"
                 }
@@ -415,9 +769,15 @@ const docTemplate = `{
                         "$ref": "#/definitions/models.Accident"
                     }
                 },
+                "cursor": {
+                    "type": "string"
+                },
                 "limit": {
                     "type": "integer"
                 },
+                "next_cursor": {
+                    "type": "string"
+                },
                 "page": {
                     "type": "integer"
                 },
@@ -429,6 +789,10 @@ const docTemplate = `{
         "models.Aircraft": {
             "type": "object",
             "properties": {
+                "aircraft_category": {
+                    "description": "Such as Fixed wing single engine or Rotorcraft",
+                    "type": "string"
+                },
                 "aircraft_make_name": {
                     "type": "string"
                 },
@@ -438,11 +802,40 @@ const docTemplate = `{
                 "aircraft_operator": {
                     "type": "string"
                 },
+                "engine_type": {
+                    "description": "Such as Reciprocating or Turbo-fan",
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "images": {
+                    "description": "Images is only populated when requested with expand=images.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AircraftImage"
+                    }
+                },
+                "number_of_seats": {
+                    "type": "integer"
+                },
+                "owner_city": {
+                    "description": "City of the registered owner",
+                    "type": "string"
+                },
+                "owner_state": {
+                    "description": "State code of the registered owner",
+                    "type": "string"
+                },
                 "registration_number": {
                     "type": "string"
+                },
+                "serial_number": {
+                    "type": "string"
+                },
+                "year_manufactured": {
+                    "description": "Registration details from the FAA aircraft registry, empty for aircraft not found in it.",
+                    "type": "integer"
                 }
             }
         },
@@ -472,9 +865,15 @@ const docTemplate = `{
                         "$ref": "#/definitions/models.Aircraft"
                     }
                 },
+                "cursor": {
+                    "type": "string"
+                },
                 "limit": {
                     "type": "integer"
                 },
+                "next_cursor": {
+                    "type": "string"
+                },
                 "page": {
                     "type": "integer"
                 },
@@ -483,6 +882,26 @@ const docTemplate = `{
                 }
             }
         },
+        "models.AircraftRegistrationResponse": {
+            "type": "object",
+            "properties": {
+                "accidents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Accident"
+                    }
+                },
+                "aircrafts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Aircraft"
+                    }
+                },
+                "registration_number": {
+                    "type": "string"
+                }
+            }
+        },
         "models.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -491,6 +910,77 @@ const docTemplate = `{
                 }
             }
         },
+        "models.IngestionRun": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_sha256": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "description": "Nil while the run is in progress",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "rows_failed": {
+                    "type": "integer"
+                },
+                "rows_inserted": {
+                    "type": "integer"
+                },
+                "rows_read": {
+                    "type": "integer"
+                },
+                "rows_unchanged": {
+                    "type": "integer"
+                },
+                "rows_updated": {
+                    "type": "integer"
+                },
+                "source_url": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "running",
+                        "succeeded",
+                        "failed",
+                        "interrupted"
+                    ]
+                }
+            }
+        },
+        "models.IngestionRunPaginatedResponse": {
+            "type": "object",
+            "properties": {
+                "ingestions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.IngestionRun"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.Injury": {
             "type": "object",
             "properties": {
@@ -520,13 +1010,19 @@ const docTemplate = `{
                 "country_name": {
                     "type": "string"
                 },
+                "geocode_failed": {
+                    "description": "GeocodeFailed flags locations imported without coordinates because geocoding failed.",
+                    "type": "boolean"
+                },
                 "id": {
                     "type": "integer"
                 },
                 "latitude": {
+                    "description": "Nil when the place could not be geocoded",
                     "type": "number"
                 },
                 "longitude": {
+                    "description": "Nil when the place could not be geocoded",
                     "type": "number"
                 },
                 "state_name": {
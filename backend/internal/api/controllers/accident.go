@@ -15,16 +15,20 @@ import (
 
 // GetAircraftsHandler returns a handler for fetching all aircraft with pagination.
 // @Summary Get a list of aircrafts
-// @Description Retrieve a list of all aircrafts with pagination.
+// @Description Retrieve a list of all aircrafts with page or cursor pagination. The total is omitted when a cursor is given.
 // @Tags Aircrafts
 // @Produce json
 // @Param page query int false "Page number"
-// @Param limit query int false "Number of aircraft per page"
+// @Param limit query int false "Number of aircraft per page, values above 100 are lowered to 100"
+// @Param cursor query string false "Cursor from next_cursor of a previous page, takes precedence over page"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending (e.g. aircraft_make_name,-id)"
 // @Success 200 {object} models.AircraftPaginatedResponse "Aircrafts data with pagination details"
 // @Failure 400 {object} models.ErrorResponse "Invalid parameters"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /aircrafts [get]
-func GetAircraftsHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetAircraftsHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 		if err != nil || page < 1 {
@@ -32,40 +36,79 @@ func GetAircraftsHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc
 			return
 		}
 
-		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-		if err != nil || limit < 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit number"})
+		limit, err := parseLimit(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sortBy, err := parseAircraftSort(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		aircrafts, totalCount, err := store.GetAircrafts(page, limit)
+		cursor := c.Query("cursor")
+		aircrafts, totalCount, nextCursor, err := store.GetAircrafts(c.Request.Context(), sortBy, newPagination(page, limit, cursor))
 		if err != nil {
-			log.WithError(err).Error("Failed to fetch aircrafts")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch aircrafts"})
+			if isInvalidCursor(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			respondStoreError(c, log, err, "Failed to fetch aircrafts")
+			return
+		}
+
+		if cursor != "" {
+			c.JSON(http.StatusOK, gin.H{
+				"aircrafts":   aircrafts,
+				"limit":       limit,
+				"cursor":      cursor,
+				"next_cursor": nextCursor,
+			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"aircrafts": aircrafts,
-			"total":     totalCount,
-			"page":      page,
-			"limit":     limit,
+			"aircrafts":   aircrafts,
+			"total":       totalCount,
+			"page":        page,
+			"limit":       limit,
+			"next_cursor": nextCursor,
 		})
 	}
 }
 
-// GetAccidentsHandler returns a handler for fetching a list of aviation accidents with pagination.
+// GetAccidentsHandler returns a handler for fetching a filtered list of aviation accidents with pagination.
 // @Summary Get a list of accidents
-// @Description Get a list of all aviation accidents with pagination.
+// @Description Get a list of aviation accidents with page or cursor pagination. All supplied filters are combined with AND.
+// @Description The total is omitted when a cursor is given.
 // @Tags Accidents
 // @Produce json
 // @Param page query int false "Page number"
-// @Param limit query int false "Number of accidents per page"
+// @Param limit query int false "Number of accidents per page, values above 100 are lowered to 100"
+// @Param cursor query string false "Cursor from next_cursor of a previous page, takes precedence over page"
+// @Param date_from query string false "Earliest event local date (YYYY-MM-DD)"
+// @Param date_to query string false "Latest event local date (YYYY-MM-DD)"
+// @Param fatal_flag query string false "Fatal flag" Enums(Yes, No)
+// @Param flight_phase query string false "Flight phase"
+// @Param far_part query string false "FAR part"
+// @Param event_type_description query string false "Event type description"
+// @Param aircraft_damage_description query string false "Aircraft damage description"
+// @Param state query string false "State name of the accident location"
+// @Param make query string false "Aircraft make name"
+// @Param model query string false "Aircraft model name"
+// @Param source query string false "Dataset the accident was imported from" Enums(FAA, NTSB)
+// @Param registration query string false "Aircraft registration, such as N12345, 12345 or n-12345"
+// @Param sort query string false "Comma separated sort fields, prefix with - for descending (e.g. -event_local_date,id)"
+// @Param expand query string false "Comma separated related records to embed: aircraft, location, injuries, images"
 // @Success 200 {object} models.AccidentPaginatedResponse "Accidents data with pagination details"
 // @Failure 400 {object} models.ErrorResponse "Invalid parameters"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /accidents [get]
-func GetAccidentsHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetAccidentsHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 		if err != nil || page < 1 {
@@ -73,24 +116,62 @@ func GetAccidentsHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc
 			return
 		}
 
-		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		limit, err := parseLimit(c)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit number"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		accidents, total, err := store.GetAccidents(page, limit)
+		filter, err := parseAccidentFilter(c)
 		if err != nil {
-			log.WithError(err).Error("Failed to get accidents")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get accidents"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sortBy, err := parseAccidentSort(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		cursor := c.Query("cursor")
+		expand, err := parseExpand(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		accidents, total, nextCursor, err := store.GetAccidents(c.Request.Context(), filter, sortBy, newPagination(page, limit, cursor))
+		if err != nil {
+			if isInvalidCursor(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+			respondStoreError(c, log, err, "Failed to get accidents")
+			return
+		}
+
+		if err := store.ExpandAccidents(c.Request.Context(), accidents, expand); err != nil {
+			respondStoreError(c, log, err, "Failed to get accidents")
+			return
+		}
+
+		if cursor != "" {
+			c.JSON(http.StatusOK, gin.H{
+				"accidents":   accidents,
+				"limit":       limit,
+				"cursor":      cursor,
+				"next_cursor": nextCursor,
+			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"accidents": accidents,
-			"total":     total,
-			"page":      page,
-			"limit":     limit,
+			"accidents":   accidents,
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+			"next_cursor": nextCursor,
 		})
 	}
 }
@@ -101,12 +182,15 @@ func GetAccidentsHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc
 // @Tags Accidents
 // @Produce json
 // @Param id path int true "Accident ID"
+// @Param expand query string false "Comma separated related records to embed: aircraft, location, injuries, images"
 // @Success 200 {object} models.Accident "Detailed accident data"
 // @Failure 400 {object} models.ErrorResponse "Invalid accident ID"
 // @Failure 404 {object} models.ErrorResponse "Accident not found"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /accidents/{id} [get]
-func GetAccidentByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetAccidentByIdHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -114,10 +198,15 @@ func GetAccidentByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerF
 			return
 		}
 
-		accident, err := store.GetAccidentById(id)
+		expand, err := parseExpand(c)
 		if err != nil {
-			log.WithError(err).Error("Failed to fetch accident")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accident"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		accident, err := store.GetAccidentById(c.Request.Context(), id)
+		if err != nil {
+			respondStoreError(c, log, err, "Failed to fetch accident")
 			return
 		}
 
@@ -126,10 +215,64 @@ func GetAccidentByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerF
 			return
 		}
 
+		if err := store.ExpandAccidents(c.Request.Context(), []*models.Accident{accident}, expand); err != nil {
+			respondStoreError(c, log, err, "Failed to fetch accident")
+			return
+		}
+
 		c.JSON(http.StatusOK, accident)
 	}
 }
 
+// GetAircraftByRegistrationHandler returns a handler for looking up the aircraft with a registration and its accidents.
+// @Summary Get an aircraft and its accidents by registration
+// @Description Retrieve every aircraft recorded with a registration, written as N12345, 12345 or n-12345, and all of their accidents oldest first
+// @Tags Aircrafts
+// @Produce json
+// @Param reg path string true "Aircraft registration"
+// @Success 200 {object} models.AircraftRegistrationResponse "Aircraft and accident history"
+// @Failure 400 {object} models.ErrorResponse "Invalid registration"
+// @Failure 404 {object} models.ErrorResponse "Aircraft not found"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
+// @Router /aircrafts/registration/{reg} [get]
+func GetAircraftByRegistrationHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registration, err := parseRegistration(c.Param("reg"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		aircrafts, err := store.GetAircraftsByRegistration(c.Request.Context(), registration)
+		if err != nil {
+			respondStoreError(c, log, err, "Failed to fetch aircraft")
+			return
+		}
+
+		if len(aircrafts) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Aircraft not found"})
+			return
+		}
+
+		accidents, err := store.GetAccidentsByRegistration(c.Request.Context(), registration)
+		if err != nil {
+			respondStoreError(c, log, err, "Failed to fetch accidents")
+			return
+		}
+		if accidents == nil {
+			accidents = []*models.Accident{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"registration_number": registration,
+			"aircrafts":           aircrafts,
+			"accidents":           accidents,
+		})
+	}
+}
+
 // GetLocationByAccidentIdHandler returns a handler for fetching location details by accident ID.
 // @Summary Get location by accident ID
 // @Description Retrieve location details of an accident by its ID
@@ -140,8 +283,10 @@ func GetAccidentByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerF
 // @Failure 400 {object} models.ErrorResponse "Invalid accident ID"
 // @Failure 404 {object} models.ErrorResponse "Location not found"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /accidents/{id}/location [get]
-func GetLocationByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetLocationByAccidentIdHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accidentId, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -149,10 +294,9 @@ func GetLocationByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.
 			return
 		}
 
-		location, err := store.GetLocationByAccidentId(accidentId)
+		location, err := store.GetLocationByAccidentId(c.Request.Context(), accidentId)
 		if err != nil {
-			log.WithError(err).Error("Failed to fetch location")
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Failed to fetch location"})
+			respondStoreError(c, log, err, "Failed to fetch location")
 			return
 		}
 
@@ -167,7 +311,7 @@ func GetLocationByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.
 
 // GetAircraftByIdHandler returns a handler for fetching an aircraft by its ID.
 // @Summary Get details about an aircraft by ID
-// @Description Retrieve details of an aircraft by its ID
+// @Description Retrieve details of an aircraft by its ID, with its FAA registry details when known
 // @Tags Aircrafts
 // @Produce json
 // @Param id path int true "Aircraft ID"
@@ -175,8 +319,10 @@ func GetLocationByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.
 // @Failure 400 {object} models.ErrorResponse "Invalid aircraft ID"
 // @Failure 404 {object} models.ErrorResponse "Aircraft not found"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /aircrafts/{id} [get]
-func GetAircraftByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetAircraftByIdHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -184,10 +330,14 @@ func GetAircraftByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerF
 			return
 		}
 
-		aircraft, err := store.GetAircraftById(id)
+		aircraft, err := store.GetAircraftById(c.Request.Context(), id)
 		if err != nil {
-			log.WithError(err).Error("Failed to fetch aircraft")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch aircraft"})
+			respondStoreError(c, log, err, "Failed to fetch aircraft")
+			return
+		}
+
+		if aircraft == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Aircraft not found"})
 			return
 		}
 
@@ -205,8 +355,10 @@ func GetAircraftByIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerF
 // @Failure 400 {object} models.ErrorResponse "Invalid aircraft ID"
 // @Failure 404 {object} models.ErrorResponse "Aircraft not found"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /aircrafts/{id}/images [get]
-func GetAllImagesForAircraftHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetAllImagesForAircraftHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -214,10 +366,9 @@ func GetAllImagesForAircraftHandler(store *store.Store, log *logrus.Logger) gin.
 			return
 		}
 
-		images, err := store.GetAllImagesForAircraft(id)
+		images, err := store.GetAllImagesForAircraft(c.Request.Context(), id)
 		if err != nil {
-			log.WithError(err).Error("Failed to fetch images for aircraft")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+			respondStoreError(c, log, err, "Failed to fetch images")
 			return
 		}
 
@@ -237,8 +388,10 @@ func GetAllImagesForAircraftHandler(store *store.Store, log *logrus.Logger) gin.
 // @Failure 400 {object} models.ErrorResponse "Invalid accident ID provided"
 // @Failure 404 {object} models.ErrorResponse "No injuries found for the specified accident ID"
 // @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
 // @Router /accidents/{id}/injuries [get]
-func GetInjuriesByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.HandlerFunc {
+func GetInjuriesByAccidentIdHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
@@ -247,14 +400,13 @@ func GetInjuriesByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.
 			return
 		}
 
-		injuries, err := store.GetInjuriesByAccidentIdHandler(id)
+		injuries, err := store.GetInjuriesByAccidentId(c.Request.Context(), id)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				log.WithField("accidentID", id).Info("No injuries found for the accident ID")
 				c.JSON(http.StatusNotFound, gin.H{"error": "No injuries found for the specified accident ID"})
 			} else {
-				log.WithField("accidentID", id).WithError(err).Error("Failed to fetch injuries")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch injuries"})
+				respondStoreError(c, log.WithField("accidentID", id), err, "Failed to fetch injuries")
 			}
 			return
 		}
@@ -270,3 +422,48 @@ func GetInjuriesByAccidentIdHandler(store *store.Store, log *logrus.Logger) gin.
 		})
 	}
 }
+
+// GetIngestionsHandler returns a handler for fetching the history of data imports with pagination.
+// @Summary Get a list of ingestion runs
+// @Description Retrieve the runs that imported FAA accident data, most recent first, with the source file and row counts of each
+// @Tags Ingestions
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Number of runs per page, values above 100 are lowered to 100"
+// @Success 200 {object} models.IngestionRunPaginatedResponse "Ingestion runs with pagination details"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
+// @Failure 503 {object} models.ErrorResponse "Request cancelled"
+// @Failure 504 {object} models.ErrorResponse "Database query timed out"
+// @Router /ingestions [get]
+func GetIngestionsHandler(store store.StoreInterface, log *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page number"})
+			return
+		}
+
+		limit, err := parseLimit(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		runs, totalCount, err := store.GetIngestionRuns(c.Request.Context(), newPagination(page, limit, ""))
+		if err != nil {
+			respondStoreError(c, log, err, "Failed to fetch ingestion runs")
+			return
+		}
+		if runs == nil {
+			runs = []*models.IngestionRun{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"ingestions": runs,
+			"total":      totalCount,
+			"page":       page,
+			"limit":      limit,
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Controller tests exercise the HTTP handlers against the MockStore,
+// so request parsing and response codes are checked without a database.
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// serve runs a single request through the handler mounted at the given route.
+func serve(route, target string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, handler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+// newTestLogger returns a logger that discards its output.
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// TestGetAccidentsHandler tests the accident listing's parameter validation and responses.
+func TestGetAccidentsHandler(t *testing.T) {
+	accidents := []*models.Accident{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		name       string
+		target     string
+		queryError error
+		wantStatus int
+	}{
+		{"Default page", "/accidents", nil, http.StatusOK},
+		{"Filtered and sorted", "/accidents?date_from=2023-01-01&state=Texas&sort=-event_local_date", nil, http.StatusOK},
+		{"NTSB accidents", "/accidents?source=ntsb", nil, http.StatusOK},
+		{"Invalid page", "/accidents?page=0", nil, http.StatusBadRequest},
+		{"Largest limit", "/accidents?limit=100", nil, http.StatusOK},
+		{"Limit above maximum", "/accidents?limit=1000", nil, http.StatusOK},
+		{"Invalid limit", "/accidents?limit=0", nil, http.StatusBadRequest},
+		{"Invalid date", "/accidents?date_from=01-01-2023", nil, http.StatusBadRequest},
+		{"Unknown source", "/accidents?source=CAA", nil, http.StatusBadRequest},
+		{"Registration", "/accidents?registration=n-12345", nil, http.StatusOK},
+		{"Blank registration", "/accidents?registration=%20-", nil, http.StatusBadRequest},
+		{"Inverted date range", "/accidents?date_from=2023-02-01&date_to=2023-01-01", nil, http.StatusBadRequest},
+		{"Unknown sort field", "/accidents?sort=remark_text", nil, http.StatusBadRequest},
+		{"Unknown expand field", "/accidents?expand=narrative", nil, http.StatusBadRequest},
+		{"Store failure", "/accidents", errors.New("query error"), http.StatusInternalServerError},
+		{"Query timeout", "/accidents", fmt.Errorf("query execution error: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"Query cancelled", "/accidents", context.Canceled, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := store.NewMockStore(nil, accidents, tt.queryError)
+			recorder := serve("/accidents", tt.target, GetAccidentsHandler(mockStore, newTestLogger()))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+// TestGetAccidentsHandler_Pagination tests that pagination and filters reach the store and the total is returned.
+func TestGetAccidentsHandler_Pagination(t *testing.T) {
+	mockStore := store.NewMockStore(nil, []*models.Accident{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+
+	recorder := serve("/accidents", "/accidents?page=2&limit=2&fatal_flag=Yes", GetAccidentsHandler(mockStore, newTestLogger()))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	var response models.AccidentPaginatedResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Total != 3 || len(response.Accidents) != 1 || response.Accidents[0].ID != 3 {
+		t.Errorf("Expected accident 3 of 3, got %+v", response)
+	}
+	if mockStore.LastFilter.FatalFlag != "Yes" {
+		t.Errorf("Expected fatal_flag filter to reach the store, got %+v", mockStore.LastFilter)
+	}
+
+	serve("/accidents", "/accidents?source=ntsb", GetAccidentsHandler(mockStore, newTestLogger()))
+	if mockStore.LastFilter.Source != store.SourceNTSB {
+		t.Errorf("Expected the source filter to reach the store as %s, got %q", store.SourceNTSB, mockStore.LastFilter.Source)
+	}
+
+	recorder = serve("/accidents", "/accidents?limit=1000&fatal_flag=No", GetAccidentsHandler(store.NewMockStore(nil, nil, nil), newTestLogger()))
+	var fields map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if fields["limit"] != float64(100) || fields["total"] != float64(0) || fields["page"] != float64(1) {
+		t.Errorf("Expected the limit lowered to 100 and an empty first page with total 0, got %v", fields)
+	}
+}
+
+// TestGetAccidentByIdHandler tests fetching a single accident, with and without expanded records.
+func TestGetAccidentByIdHandler(t *testing.T) {
+	mockStore := store.NewMockStore(
+		[]*models.Aircraft{{ID: 5, RegistrationNumber: "N12345"}},
+		[]*models.Accident{{ID: 1, AircraftID: 5}},
+		nil,
+	)
+	handler := GetAccidentByIdHandler(mockStore, newTestLogger())
+
+	if recorder := serve("/accidents/:id", "/accidents/2", handler); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing accident, got %d", recorder.Code)
+	}
+	if recorder := serve("/accidents/:id", "/accidents/abc", handler); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid ID, got %d", recorder.Code)
+	}
+
+	recorder := serve("/accidents/:id", "/accidents/1?expand=aircraft", handler)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	var accident models.Accident
+	if err := json.Unmarshal(recorder.Body.Bytes(), &accident); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if accident.Aircraft == nil || accident.Aircraft.RegistrationNumber != "N12345" {
+		t.Errorf("Expected embedded aircraft N12345, got %+v", accident.Aircraft)
+	}
+}
+
+// TestGetAircraftByIdHandler tests that a missing aircraft is reported as not found.
+func TestGetAircraftByIdHandler(t *testing.T) {
+	mockStore := store.NewMockStore([]*models.Aircraft{{ID: 5}}, nil, nil)
+	handler := GetAircraftByIdHandler(mockStore, newTestLogger())
+
+	if recorder := serve("/aircrafts/:id", "/aircrafts/5", handler); recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", recorder.Code)
+	}
+	if recorder := serve("/aircrafts/:id", "/aircrafts/6", handler); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", recorder.Code)
+	}
+}
+
+// TestGetAircraftByRegistrationHandler tests that a registration is normalized, that every aircraft recorded with it
+// is returned with its accidents oldest first, and that unknown registrations are not found.
+func TestGetAircraftByRegistrationHandler(t *testing.T) {
+	mockStore := store.NewMockStore(
+		[]*models.Aircraft{{ID: 5, RegistrationNumber: "N12345"}, {ID: 6, RegistrationNumber: "12345"}, {ID: 7, RegistrationNumber: "N67890"}},
+		[]*models.Accident{
+			{ID: 1, AircraftID: 5, EventLocalDate: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 2, AircraftID: 7, EventLocalDate: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 3, AircraftID: 6, EventLocalDate: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		nil,
+	)
+	handler := GetAircraftByRegistrationHandler(mockStore, newTestLogger())
+
+	recorder := serve("/aircrafts/registration/:reg", "/aircrafts/registration/n-12345", handler)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	var response models.AircraftRegistrationResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.RegistrationNumber != "N12345" || len(response.Aircrafts) != 2 ||
+		len(response.Accidents) != 2 || response.Accidents[0].ID != 3 || response.Accidents[1].ID != 1 {
+		t.Errorf("Expected aircraft 5 and 6 with accidents 3 and 1, got %+v", response)
+	}
+
+	if recorder := serve("/aircrafts/registration/:reg", "/aircrafts/registration/N99999", handler); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", recorder.Code)
+	}
+	if recorder := serve("/aircrafts/registration/:reg", "/aircrafts/registration/-", handler); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", recorder.Code)
+	}
+}
+
+// TestGetIngestionsHandler tests that ingestion runs are listed a page at a time.
+func TestGetIngestionsHandler(t *testing.T) {
+	mockStore := store.NewMockStore(nil, nil, nil)
+	mockStore.Ingestions = []*models.IngestionRun{{ID: 3, Status: "succeeded"}, {ID: 2, Status: "failed"}, {ID: 1, Status: "succeeded"}}
+	handler := GetIngestionsHandler(mockStore, newTestLogger())
+
+	if recorder := serve("/ingestions", "/ingestions?limit=0", handler); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid limit, got %d", recorder.Code)
+	}
+
+	recorder := serve("/ingestions", "/ingestions?page=1&limit=2", handler)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	var response models.IngestionRunPaginatedResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Total != 3 || len(response.Ingestions) != 2 || response.Ingestions[0].ID != 3 {
+		t.Errorf("Expected runs 3 and 2 of 3, got %+v", response)
+	}
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// dateLayout is the format accepted for date query parameters.
+const dateLayout = "2006-01-02"
+
+// parseAccidentFilter builds an AccidentFilter from the request's query parameters.
+func parseAccidentFilter(c *gin.Context) (store.AccidentFilter, error) {
+	filter := store.AccidentFilter{
+		FatalFlag:                 c.Query("fatal_flag"),
+		FlightPhase:               c.Query("flight_phase"),
+		FARPart:                   c.Query("far_part"),
+		EventTypeDescription:      c.Query("event_type_description"),
+		AircraftDamageDescription: c.Query("aircraft_damage_description"),
+		StateName:                 c.Query("state"),
+		AircraftMakeName:          c.Query("make"),
+		AircraftModelName:         c.Query("model"),
+		Source:                    c.Query("source"),
+	}
+
+	if value := c.Query("date_from"); value != "" {
+		from, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return filter, errors.New("Invalid date_from, expected YYYY-MM-DD")
+		}
+		filter.EventDateFrom = &from
+	}
+
+	if value := c.Query("date_to"); value != "" {
+		to, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return filter, errors.New("Invalid date_to, expected YYYY-MM-DD")
+		}
+		filter.EventDateTo = &to
+	}
+
+	if filter.EventDateFrom != nil && filter.EventDateTo != nil && filter.EventDateFrom.After(*filter.EventDateTo) {
+		return filter, errors.New("date_from must not be after date_to")
+	}
+
+	if filter.FatalFlag != "" && filter.FatalFlag != "Yes" && filter.FatalFlag != "No" {
+		return filter, errors.New("Invalid fatal_flag, expected Yes or No")
+	}
+
+	if value, ok := c.GetQuery("registration"); ok {
+		registration, err := parseRegistration(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Registration = registration
+	}
+
+	// Sources are stored as FAA or NTSB, and only MySQL and SQLite compare them case-insensitively
+	switch {
+	case filter.Source == "":
+	case strings.EqualFold(filter.Source, store.SourceFAA):
+		filter.Source = store.SourceFAA
+	case strings.EqualFold(filter.Source, store.SourceNTSB):
+		filter.Source = store.SourceNTSB
+	default:
+		return filter, errors.New("Invalid source, expected FAA or NTSB")
+	}
+
+	return filter, nil
+}
+
+// parseRegistration normalizes a registration given in the request with store.NormalizeRegistration, so "N12345",
+// "12345" and "n-12345" match the same aircraft, and rejects a blank one.
+func parseRegistration(value string) (string, error) {
+	registration := store.NormalizeRegistration(value)
+	if registration == "" {
+		return "", errors.New("Invalid registration")
+	}
+	return registration, nil
+}
+
+// parseAccidentSort parses the request's sort query parameter against the sortable accident fields.
+func parseAccidentSort(c *gin.Context) (store.Sort, error) {
+	sortBy, err := store.ParseAccidentSort(c.Query("sort"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sort parameter: %w", err)
+	}
+	return sortBy, nil
+}
+
+// parseAircraftSort parses the request's sort query parameter against the sortable aircraft fields.
+func parseAircraftSort(c *gin.Context) (store.Sort, error) {
+	sortBy, err := store.ParseAircraftSort(c.Query("sort"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sort parameter: %w", err)
+	}
+	return sortBy, nil
+}
+
+// maxLimit is the largest page size returned, so a single request cannot read a whole table.
+const maxLimit = 100
+
+// parseLimit parses the request's limit query parameter, 10 by default. Larger limits than maxLimit are lowered to
+// it rather than rejected, so clients asking for bigger pages keep working and page through the rest.
+func parseLimit(c *gin.Context) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		return 0, errors.New("Invalid limit number")
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, nil
+}
+
+// newPagination builds the store pagination for a request's page, limit and cursor parameters.
+func newPagination(page, limit int, cursor string) store.Pagination {
+	return store.Pagination{Page: page, Limit: limit, Cursor: cursor}
+}
+
+// isInvalidCursor reports whether the store rejected the request's cursor.
+func isInvalidCursor(err error) bool {
+	return errors.Is(err, store.ErrInvalidCursor)
+}
+
+// parseExpand parses the request's expand query parameter.
+func parseExpand(c *gin.Context) (store.Expand, error) {
+	expand, err := store.ParseExpand(c.Query("expand"))
+	if err != nil {
+		return expand, fmt.Errorf("Invalid expand parameter: %w", err)
+	}
+	return expand, nil
+}
+
+// respondStoreError logs a failed store call and writes the matching error response:
+// 504 when the query timed out, 503 when it was cancelled by the client or a server shutdown, 500 otherwise.
+func respondStoreError(c *gin.Context, log logrus.FieldLogger, err error, message string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.WithError(err).Warn(message + ": query timed out")
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Database query timed out"})
+	case errors.Is(err, context.Canceled):
+		log.WithError(err).Warn(message + ": request cancelled")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Request cancelled"})
+	default:
+		log.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 // NewRouter initializes a new Gin web server with custom logging and routing configured.
-func NewRouter(store *store.Store) *gin.Engine {
+func NewRouter(store store.StoreInterface) *gin.Engine {
 	log := logrus.New()
 	log.SetFormatter(&logrus.JSONFormatter{})
 	router := SetupRouter(store, log)
@@ -22,7 +22,7 @@ func NewRouter(store *store.Store) *gin.Engine {
 }
 
 // SetupRouter configures a Gin router with necessary routes, middleware, and CORS policies.
-func SetupRouter(store *store.Store, log *logrus.Logger) *gin.Engine {
+func SetupRouter(store store.StoreInterface, log *logrus.Logger) *gin.Engine {
 	router := gin.Default()
 
 	config := cors.DefaultConfig()
@@ -40,6 +40,7 @@ func SetupRouter(store *store.Store, log *logrus.Logger) *gin.Engine {
 		{
 			aircrafts.GET("", controllers.GetAircraftsHandler(store, log))
 			aircrafts.GET("/:id", controllers.GetAircraftByIdHandler(store, log))
+			aircrafts.GET("/registration/:reg", controllers.GetAircraftByRegistrationHandler(store, log))
 			aircrafts.GET("/:id/accidents", controllers.GetAccidentByIdHandler(store, log))
 			aircrafts.GET("/:id/images", controllers.GetAllImagesForAircraftHandler(store, log))
 		}
@@ -51,6 +52,8 @@ func SetupRouter(store *store.Store, log *logrus.Logger) *gin.Engine {
 			accidents.GET("/:id/location", controllers.GetLocationByAccidentIdHandler(store, log))
 			accidents.GET("/:id/injuries", controllers.GetInjuriesByAccidentIdHandler(store, log))
 		}
+
+		v1.GET("/ingestions", controllers.GetIngestionsHandler(store, log))
 	}
 
 	return router
@@ -36,6 +36,8 @@ func GracefulShutdown(server *http.Server) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
+		// Closing the remaining connections cancels their request contexts, which aborts in-flight database queries.
+		server.Close()
 		log.Fatalf("Failed to shutdown server: %v", err)
 	}
 
@@ -0,0 +1,193 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+)
+
+// maxPageRedirects limits the meta refresh redirects followed to reach the download page; HTTP redirects
+// are followed by the client.
+const maxPageRedirects = 5
+
+// maxPageSize limits the size of the download page read.
+const maxPageSize = 10 << 20
+
+// NewSessionClient returns a client with a cookie jar, so the APEX session started while finding the link is
+// used to download the file.
+func NewSessionClient(timeout time.Duration) *http.Client {
+	jar, _ := cookiejar.New(nil) // Only fails on invalid options
+	return &http.Client{Jar: jar, Timeout: timeout}
+}
+
+// FindLink finds the CSV download link on the FAA page with a strategy: html parses the page, chromedp renders
+// it in a headless Chrome, and auto parses it and falls back to chromedp.
+func FindLink(ctx context.Context, strategy string, client *http.Client, pageURL string) (string, error) {
+	switch strategy {
+	case "html":
+		return findCsvDownloadLink(ctx, client, pageURL)
+	case "chromedp":
+		return fetchCsvDownloadLink(ctx, pageURL)
+	case "auto":
+		link, err := findCsvDownloadLink(ctx, client, pageURL)
+		if err == nil || ctx.Err() != nil {
+			return link, err
+		}
+		log.Printf("Falling back to chromedp, HTML link discovery failed: %v", err)
+		return fetchCsvDownloadLink(ctx, pageURL)
+	default:
+		return "", fmt.Errorf("unknown discovery strategy %q", strategy)
+	}
+}
+
+// fetchCsvDownloadLink fetches the CSV download link from a given URL using chromedp.
+func fetchCsvDownloadLink(ctx context.Context, pageURL string) (string, error) {
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	var downloadLink string
+	if err := chromedp.Run(ctx,
+		chromedp.Navigate(pageURL),
+		chromedp.WaitVisible(`#div_wid a.bodytextlink`),
+		chromedp.Evaluate(`document.querySelector('#div_wid a.bodytextlink').href`, &downloadLink),
+	); err != nil {
+		return "", err
+	}
+
+	if downloadLink == "" {
+		return "", fmt.Errorf("CSV download link not found")
+	}
+
+	return downloadLink, nil
+}
+
+// findCsvDownloadLink fetches the FAA download page over plain HTTP and returns the CSV link in
+// #div_wid a.bodytextlink, resolved against the page's final URL. Oracle APEX redirects the first
+// request to a URL carrying a new session ID and sets a session cookie, so the client needs a cookie
+// jar, which must also be used to download the file.
+func findCsvDownloadLink(ctx context.Context, client *http.Client, pageURL string) (string, error) {
+	for redirects := 0; ; redirects++ {
+		doc, finalURL, err := fetchPage(ctx, client, pageURL)
+		if err != nil {
+			return "", err
+		}
+
+		if href, ok := findDownloadHref(doc); ok {
+			link, err := finalURL.Parse(strings.TrimSpace(href))
+			if err != nil {
+				return "", fmt.Errorf("invalid CSV download link %q: %w", href, err)
+			}
+			return link.String(), nil
+		}
+
+		// APEX answers some requests without a session with a page that refreshes to the session URL.
+		refresh, ok := findMetaRefresh(doc)
+		if !ok || redirects >= maxPageRedirects {
+			return "", fmt.Errorf("CSV download link not found")
+		}
+		next, err := finalURL.Parse(refresh)
+		if err != nil {
+			return "", fmt.Errorf("invalid refresh URL %q: %w", refresh, err)
+		}
+		pageURL = next.String()
+	}
+}
+
+// fetchPage fetches and parses an HTML page, returning it with the URL it was served from after redirects.
+func fetchPage(ctx context.Context, client *http.Client, pageURL string) (*html.Node, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching download page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("error fetching download page: server responded with %s", resp.Status)
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing download page: %w", err)
+	}
+	return doc, resp.Request.URL, nil
+}
+
+// findDownloadHref returns the href of the first a.bodytextlink inside #div_wid, the same element the
+// chromedp strategy waits for.
+func findDownloadHref(doc *html.Node) (string, bool) {
+	container := findElement(doc, func(n *html.Node) bool { return attr(n, "id") == "div_wid" })
+	if container == nil {
+		return "", false
+	}
+	link := findElement(container, func(n *html.Node) bool {
+		return n.Data == "a" && hasClass(n, "bodytextlink") && attr(n, "href") != ""
+	})
+	if link == nil {
+		return "", false
+	}
+	return attr(link, "href"), true
+}
+
+// findMetaRefresh returns the URL of a <meta http-equiv="refresh" content="0; url=..."> tag.
+func findMetaRefresh(doc *html.Node) (string, bool) {
+	meta := findElement(doc, func(n *html.Node) bool {
+		return n.Data == "meta" && strings.EqualFold(attr(n, "http-equiv"), "refresh")
+	})
+	if meta == nil {
+		return "", false
+	}
+	_, target, ok := strings.Cut(attr(meta, "content"), ";")
+	if !ok {
+		return "", false
+	}
+	target = strings.TrimSpace(target)
+	if len(target) < 4 || !strings.EqualFold(target[:4], "url=") {
+		return "", false
+	}
+	return strings.Trim(strings.TrimSpace(target[4:]), `'"`), true
+}
+
+// findElement returns the first element in document order below n that matches, or nil.
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an element's attribute, or "" if it is not set.
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasClass reports whether an element's class attribute contains class.
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
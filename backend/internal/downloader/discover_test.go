@@ -0,0 +1,100 @@
+// Discovery tests serve saved copies of the FAA download page from an httptest server that mimics
+// the APEX session redirect.
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newAPEXServer serves the download page fixture the way Oracle APEX does: a request without a session
+// is sent to the session URL, either by an HTTP redirect or a meta refresh page, with a session cookie,
+// and the session URLs require the cookie.
+func newAPEXServer(t *testing.T, page, sessionRedirect string) *httptest.Server {
+	t.Helper()
+
+	fixture, err := os.ReadFile(filepath.Join("testdata", page))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	refresh, err := os.ReadFile(filepath.Join("testdata", "faa_session_refresh.html"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apex/f" {
+			http.NotFound(w, r)
+			return
+		}
+		p := r.URL.Query().Get("p")
+		if !strings.Contains(p, "12345678") {
+			http.SetCookie(w, &http.Cookie{Name: "ORA_WWV_APP_100", Value: "session", Path: "/"})
+			if sessionRedirect == "meta" {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write(refresh)
+				return
+			}
+			http.Redirect(w, r, "/apex/f?p=100:93:12345678::NO:::", http.StatusFound)
+			return
+		}
+		if cookie, err := r.Cookie("ORA_WWV_APP_100"); err != nil || cookie.Value != "session" {
+			http.Error(w, "session expired", http.StatusForbidden)
+			return
+		}
+		if strings.Contains(p, "AID_DATA.CSV") {
+			serveTestCSV(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(fixture)
+	}))
+}
+
+// TestFindCsvDownloadLink tests finding the link after HTTP and meta refresh session redirects.
+func TestFindCsvDownloadLink(t *testing.T) {
+	for _, redirect := range []string{"http", "meta"} {
+		t.Run(redirect, func(t *testing.T) {
+			server := newAPEXServer(t, "faa_page.html", redirect)
+			defer server.Close()
+
+			client := NewSessionClient(0)
+			link, err := findCsvDownloadLink(context.Background(), client, server.URL+"/apex/f?p=100:93:::NO:::")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			expected := server.URL + "/apex/f?p=100:93:12345678::NO::P93_FILE:AID_DATA.CSV&c=FAA"
+			if link != expected {
+				t.Fatalf("Expected link %s, got %s", expected, link)
+			}
+
+			// The download needs the session cookie set while finding the link.
+			d := newTestDownloader()
+			d.Client = client
+			if _, err := d.Download(context.Background(), link, filepath.Join(t.TempDir(), "faa.csv")); err != nil {
+				t.Errorf("Expected the link to download in the same session, got %v", err)
+			}
+		})
+	}
+}
+
+// TestFindCsvDownloadLink_NotFound tests that a page without the link is an error.
+func TestFindCsvDownloadLink_NotFound(t *testing.T) {
+	server := newAPEXServer(t, "faa_page_no_link.html", "http")
+	defer server.Close()
+
+	_, err := findCsvDownloadLink(context.Background(), NewSessionClient(0), server.URL+"/apex/f?p=100:93:::NO:::")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a link not found error, got %v", err)
+	}
+
+	_, err = FindLink(context.Background(), "browser", NewSessionClient(0), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "unknown discovery strategy") {
+		t.Errorf("Expected an unknown strategy error, got %v", err)
+	}
+}
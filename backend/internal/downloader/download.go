@@ -0,0 +1,261 @@
+// Package downloader finds and downloads the FAA accident and incident data CSV file.
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Downloader fetches a file over HTTP, retrying transient failures with exponential backoff and
+// resuming interrupted transfers. The file is written next to its destination and renamed into
+// place once complete, so a failed download never replaces a good file.
+type Downloader struct {
+	Client     *http.Client
+	Attempts   int           // Maximum number of requests per download
+	Backoff    time.Duration // Delay before the first retry, doubled after each failed attempt
+	MaxBackoff time.Duration // Longest delay between retries
+	MinSize    int64         // Smallest plausible file; anything shorter is an error page or a truncated export
+	MaxSize    int64         // Largest accepted file
+}
+
+// RegisterFlags defines command line flags for the download settings shared by the downloader and the ingestion daemon.
+func (d *Downloader) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&d.Attempts, "attempts", 5, "maximum number of download attempts")
+	fs.DurationVar(&d.Backoff, "backoff", 2*time.Second, "delay before the first retry, doubled after each failed attempt")
+	fs.DurationVar(&d.MaxBackoff, "max-backoff", time.Minute, "longest delay between retries")
+	fs.DurationVar(&d.Client.Timeout, "timeout", 10*time.Minute, "maximum duration of a single download attempt")
+	fs.Int64Var(&d.MinSize, "min-size", 1<<10, "smallest plausible CSV file in bytes")
+	fs.Int64Var(&d.MaxSize, "max-size", 1<<30, "largest accepted CSV file in bytes")
+}
+
+// Result describes a completed download.
+type Result struct {
+	Size    int64
+	SHA256  string
+	Changed bool // Whether the file differs from the one previously at the destination
+}
+
+// permanentError is a download failure that retrying will not fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// rejectedContentTypes are returned by the FAA site for error and session-expired pages instead of the CSV file.
+var rejectedContentTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+	"application/json":      true,
+	"text/xml":              true,
+	"application/xml":       true,
+}
+
+// partialDownload is a download in progress, kept in a temporary file between attempts.
+type partialDownload struct {
+	file      *os.File
+	size      int64  // Bytes received so far
+	validator string // ETag or Last-Modified of the response being resumed, empty when it cannot be resumed
+}
+
+// Download fetches url into path, returning the size and SHA-256 of the file. The checksum is also
+// written to path + ".sha256" in sha256sum format, so the importer can verify the file it reads.
+func (d *Downloader) Download(ctx context.Context, url, path string) (*Result, error) {
+	partPath := path + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer func() {
+		file.Close()
+		os.Remove(partPath)
+	}()
+
+	part := &partialDownload{file: file}
+	backoff := d.Backoff
+	for attempt := 1; ; attempt++ {
+		err = d.fetch(ctx, url, part)
+		if err == nil {
+			break
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || ctx.Err() != nil || attempt >= d.Attempts {
+			return nil, err
+		}
+
+		log.Printf("Download attempt %d of %d failed, retrying in %s: %v", attempt, d.Attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		if backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+
+	if part.size < d.MinSize {
+		return nil, fmt.Errorf("downloaded file is %d bytes, expected at least %d", part.size, d.MinSize)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("error writing temporary file: %w", err)
+	}
+	sum, err := hashFile(partPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Size: part.size, SHA256: sum, Changed: true}
+	if previous, err := hashFile(path); err == nil && previous == sum {
+		result.Changed = false
+		return result, writeChecksum(path, sum)
+	}
+
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return nil, fmt.Errorf("error moving download into place: %w", err)
+	}
+	return result, writeChecksum(path, sum)
+}
+
+// fetch makes one request, appending to the partial download when the server can resume it
+// and starting over otherwise.
+func (d *Downloader) fetch(ctx context.Context, url string, part *partialDownload) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &permanentError{err}
+	}
+	if part.size > 0 && part.validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", part.size))
+		req.Header.Set("If-Range", part.validator)
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", part.size)):
+		// Resuming where the previous attempt stopped.
+	case resp.StatusCode == http.StatusOK:
+		if err := part.reset(); err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("server responded with %s", resp.Status)
+	case resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The server cannot resume this transfer; start over without a range.
+		part.validator = ""
+		if err := part.reset(); err != nil {
+			return err
+		}
+		return fmt.Errorf("server could not resume the download: %s", resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("server responded with %s", resp.Status)}
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return &permanentError{err}
+	}
+	if resp.ContentLength > 0 && part.size+resp.ContentLength > d.MaxSize {
+		return &permanentError{fmt.Errorf("file is %d bytes, more than the %d allowed", part.size+resp.ContentLength, d.MaxSize)}
+	}
+
+	part.validator = resp.Header.Get("ETag")
+	if part.validator == "" || strings.HasPrefix(part.validator, "W/") {
+		// Weak ETags cannot be used with If-Range.
+		part.validator = resp.Header.Get("Last-Modified")
+	}
+
+	n, err := io.Copy(part.file, io.LimitReader(resp.Body, d.MaxSize-part.size+1))
+	part.size += n
+	if err != nil {
+		return fmt.Errorf("error reading response after %d bytes: %w", part.size, err)
+	}
+	if part.size > d.MaxSize {
+		return &permanentError{fmt.Errorf("file is more than the %d bytes allowed", d.MaxSize)}
+	}
+	if resp.ContentLength > 0 && n < resp.ContentLength {
+		return fmt.Errorf("response ended after %d of %d bytes", n, resp.ContentLength)
+	}
+	return nil
+}
+
+// reset discards the bytes received so far.
+func (p *partialDownload) reset() error {
+	if err := p.file.Truncate(0); err != nil {
+		return fmt.Errorf("error truncating temporary file: %w", err)
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error truncating temporary file: %w", err)
+	}
+	p.size = 0
+	return nil
+}
+
+// checkContentType rejects responses that are web pages or API errors rather than a data file.
+// Servers label CSV exports inconsistently, so other types, or none, are accepted.
+func checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	if rejectedContentTypes[mediaType] {
+		return fmt.Errorf("server sent %s instead of a CSV file", mediaType)
+	}
+	return nil
+}
+
+// hashFile returns the hex SHA-256 of a file's contents.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeChecksum records the SHA-256 of the file at path in path + ".sha256", in the format read by sha256sum -c.
+func writeChecksum(path, sum string) error {
+	line := sum + "  " + filepath.Base(path) + "\n"
+	if err := os.WriteFile(path+".sha256", []byte(line), 0o644); err != nil {
+		return fmt.Errorf("error writing checksum: %w", err)
+	}
+	return nil
+}
+
+// FormatSize formats a byte count for log messages.
+func FormatSize(n int64) string {
+	if n < 1<<20 {
+		return strconv.FormatInt(n, 10) + " bytes"
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,156 @@
+// Download tests serve CSV files from an httptest server that fails, drops connections and sends error pages.
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testCSV is the file served by the test servers.
+var testCSV = bytes.Repeat([]byte("UPDATED,ENTRY_DATE,EVENT_LCL_DATE\nNo,01-JAN-23,01-JAN-23\n"), 100)
+
+// newTestDownloader returns a downloader that retries quickly.
+func newTestDownloader() *Downloader {
+	return &Downloader{
+		Client:     &http.Client{},
+		Attempts:   3,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		MinSize:    10,
+		MaxSize:    1 << 20,
+	}
+}
+
+// serveTestCSV serves testCSV with an ETag, so interrupted downloads can be resumed.
+func serveTestCSV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("ETag", `"v1"`)
+	http.ServeContent(w, r, "faa.csv", time.Time{}, bytes.NewReader(testCSV))
+}
+
+// TestDownload_Retry tests that server errors are retried and that the file and its checksum are written.
+func TestDownload_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		serveTestCSV(w, r)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "faa.csv")
+	result, err := newTestDownloader().Download(context.Background(), server.URL, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	hash := sha256.Sum256(testCSV)
+	sum := hex.EncodeToString(hash[:])
+	if !result.Changed || result.SHA256 != sum || result.Size != int64(len(testCSV)) || requests.Load() != 2 {
+		t.Errorf("Expected a changed %d byte file with SHA-256 %s after 2 requests, got %+v after %d", len(testCSV), sum, result, requests.Load())
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, testCSV) {
+		t.Errorf("Expected the downloaded file to match, got %d bytes, %v", len(data), err)
+	}
+	if data, err := os.ReadFile(path + ".sha256"); err != nil || string(data) != sum+"  faa.csv\n" {
+		t.Errorf("Expected checksum file %q, got %q, %v", sum+"  faa.csv\n", data, err)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+
+	result, err = newTestDownloader().Download(context.Background(), server.URL, path)
+	if err != nil || result.Changed {
+		t.Errorf("Expected the second download to be unchanged, got %+v, %v", result, err)
+	}
+}
+
+// TestDownload_Resume tests that a dropped connection is resumed from where it stopped.
+func TestDownload_Resume(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", "5000")
+			w.Write(testCSV[:1000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		serveTestCSV(w, r)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "faa.csv")
+	if _, err := newTestDownloader().Download(context.Background(), server.URL, path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Join(ranges, ",") != ",bytes=1000-" {
+		t.Errorf("Expected the second request to resume at byte 1000, got ranges %q", ranges)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, testCSV) {
+		t.Errorf("Expected the resumed file to match, got %d bytes, %v", len(data), err)
+	}
+}
+
+// TestDownload_Rejected tests that error pages and oversized files fail without retrying or replacing the existing file.
+func TestDownload_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		maxSize int64
+		wantErr string
+	}{
+		{"HTML page", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>Your session has expired</html>"))
+		}, 1 << 20, "text/html"},
+		{"Too large", serveTestCSV, 100, "more than the 100"},
+		{"Too small", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("x"))
+		}, 1 << 20, "expected at least"},
+		{"Not found", http.NotFound, 1 << 20, "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				tt.handler(w, r)
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "faa.csv")
+			if err := os.WriteFile(path, []byte("previous"), 0o644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			d := newTestDownloader()
+			d.MaxSize = tt.maxSize
+			_, err := d.Download(context.Background(), server.URL, path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if requests.Load() != 1 {
+				t.Errorf("Expected no retries, got %d requests", requests.Load())
+			}
+			if data, _ := os.ReadFile(path); string(data) != "previous" {
+				t.Errorf("Expected the existing file to be kept, got %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// faaColumn is a column of the FAA accident and incident CSV export that the importer reads.
+type faaColumn int
+
+const (
+	colUpdated faaColumn = iota
+	colEntryDate
+	colEventLocalDate
+	colEventLocalTime
+	colCityName
+	colStateName
+	colCountryName
+	colRemarkText
+	colEventType
+	colFSDO
+	colRegistration
+	colFlightNumber
+	colOperator
+	colMakeName
+	colModelName
+	colMissingFlag
+	colDamage
+	colFlightActivity
+	colFlightPhase
+	colFARPart
+	colMaxInjuryLevel
+	colFatalFlag
+	numFAAColumns
+)
+
+// faaColumnHeaders is the header name of each column. Columns are looked up by these names rather than by
+// position, so reordered or added columns in a new export are still read correctly.
+var faaColumnHeaders = [numFAAColumns]string{
+	colUpdated:        "UPDATED",
+	colEntryDate:      "ENTRY_DATE",
+	colEventLocalDate: "EVENT_LCL_DATE",
+	colEventLocalTime: "EVENT_LCL_TIME",
+	colCityName:       "LOC_CITY_NAME",
+	colStateName:      "LOC_STATE_NAME",
+	colCountryName:    "LOC_CNTRY_NAME",
+	colRemarkText:     "RMK_TEXT",
+	colEventType:      "EVENT_TYPE_DESC",
+	colFSDO:           "FSDO_DESC",
+	colRegistration:   "REGIST_NBR",
+	colFlightNumber:   "FLT_NBR",
+	colOperator:       "ACFT_OPRTR",
+	colMakeName:       "ACFT_MAKE_NAME",
+	colModelName:      "ACFT_MODEL_NAME",
+	colMissingFlag:    "ACFT_MISSING_FLAG",
+	colDamage:         "ACFT_DMG_DESC",
+	colFlightActivity: "FLT_ACTIVITY",
+	colFlightPhase:    "FLT_PHASE",
+	colFARPart:        "FAR_PART",
+	colMaxInjuryLevel: "MAX_INJ_LVL",
+	colFatalFlag:      "FATAL_FLAG",
+}
+
+// injuryColumnPrefixes and injuryColumnSuffixes name the injury count columns: the count of passengers
+// with minor injuries is in PAX_INJ_MINOR, for example.
+var (
+	injuryColumnPrefixes = map[string]string{
+		"flight_crew": "FLT_CRW",
+		"cabin_crew":  "CBN_CRW",
+		"passengers":  "PAX",
+		"ground":      "GRND",
+	}
+	injuryColumnSuffixes = map[string]string{
+		"none":    "NONE",
+		"minor":   "MINOR",
+		"serious": "SERIOUS",
+		"fatal":   "FATAL",
+		"unknown": "UNK",
+	}
+)
+
+// injuryColumn is the column counting people of a type with injuries of a severity.
+type injuryColumn struct {
+	PersonType     string
+	InjurySeverity string
+	Header         string
+}
+
+// injuryColumns lists the injury count columns in a stable order.
+func injuryColumns() []injuryColumn {
+	var columns []injuryColumn
+	for personType, prefix := range injuryColumnPrefixes {
+		for severity, suffix := range injuryColumnSuffixes {
+			columns = append(columns, injuryColumn{personType, severity, prefix + "_INJ_" + suffix})
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Header < columns[j].Header })
+	return columns
+}
+
+// columnMap locates the columns the importer reads in a CSV file, from the file's header.
+type columnMap struct {
+	width    int                // Number of fields in the header, and so in every record
+	columns  [numFAAColumns]int // Index of each faaColumn
+	injuries []injuryColumn     // Injury count columns
+	injuryAt []int              // Index of each of the injury count columns
+	ignored  []string           // Header columns the importer does not read
+}
+
+// schemaDriftError reports a CSV header that no longer matches the columns the importer reads.
+type schemaDriftError struct {
+	Missing    []string          // Columns the importer reads that are not in the header
+	Renamed    map[string]string // Missing columns and the unexpected header each most resembles
+	Duplicate  []string          // Columns the importer reads that appear more than once
+	Unexpected []string          // Columns in the header that the importer does not read
+}
+
+// Error lists the differences between the header and the expected columns, one per line.
+func (e *schemaDriftError) Error() string {
+	var b strings.Builder
+	b.WriteString("CSV header does not match the FAA export format:")
+	for _, name := range e.Missing {
+		if renamed, ok := e.Renamed[name]; ok {
+			fmt.Fprintf(&b, "\n  missing column %s (renamed to %s?)", name, renamed)
+		} else {
+			fmt.Fprintf(&b, "\n  missing column %s", name)
+		}
+	}
+	for _, name := range e.Duplicate {
+		fmt.Fprintf(&b, "\n  duplicate column %s", name)
+	}
+	for _, name := range e.Unexpected {
+		fmt.Fprintf(&b, "\n  unexpected column %s", name)
+	}
+	return b.String()
+}
+
+// maxRenameDistance is the largest edit distance between a missing column and an unexpected one
+// for the report to suggest that the column was renamed.
+const maxRenameDistance = 3
+
+// normalizeHeader trims a header name, including the byte order mark some exports start with, and uppercases it.
+func normalizeHeader(name string) string {
+	return strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+// newColumnMap locates the expected columns in the header, before any record is read. It returns a
+// *schemaDriftError when a column is missing or appears more than once; columns the importer does not
+// read are allowed.
+func newColumnMap(header []string) (*columnMap, error) {
+	m := &columnMap{width: len(header), injuries: injuryColumns()}
+	drift := &schemaDriftError{Renamed: make(map[string]string)}
+
+	indexes := make(map[string]int)
+	duplicates := make(map[string]bool)
+	for i, name := range header {
+		name = normalizeHeader(name)
+		if _, ok := indexes[name]; ok {
+			duplicates[name] = true
+		}
+		indexes[name] = i
+	}
+
+	expected := make(map[string]bool)
+	resolve := func(name string) int {
+		expected[name] = true
+		if duplicates[name] {
+			drift.Duplicate = append(drift.Duplicate, name)
+		}
+		i, ok := indexes[name]
+		if !ok {
+			drift.Missing = append(drift.Missing, name)
+			return -1
+		}
+		return i
+	}
+	for c, name := range faaColumnHeaders {
+		m.columns[c] = resolve(name)
+	}
+	for _, column := range m.injuries {
+		m.injuryAt = append(m.injuryAt, resolve(column.Header))
+	}
+
+	for _, name := range header {
+		if name = normalizeHeader(name); !expected[name] {
+			m.ignored = append(m.ignored, name)
+		}
+	}
+	drift.Unexpected = m.ignored
+
+	// A missing column that closely resembles an unexpected one has most likely been renamed.
+	for _, name := range drift.Missing {
+		best, bestDistance := "", maxRenameDistance+1
+		for _, candidate := range drift.Unexpected {
+			if d := levenshtein(name, candidate, maxRenameDistance); d < bestDistance {
+				best, bestDistance = candidate, d
+			}
+		}
+		if best != "" {
+			drift.Renamed[name] = best
+		}
+	}
+
+	if len(drift.Missing) > 0 || len(drift.Duplicate) > 0 {
+		return nil, drift
+	}
+	return m, nil
+}
+
+// faaRecord is a CSV record whose fields are read by column.
+type faaRecord struct {
+	columns *columnMap
+	fields  []string
+}
+
+// newFAARecord checks that the record has as many fields as the header.
+func newFAARecord(columns *columnMap, fields []string) (faaRecord, error) {
+	if len(fields) != columns.width {
+		err := fmt.Errorf("record has %d fields, but the header has %d", len(fields), columns.width)
+		return faaRecord{}, &fieldError{Reason: reasonFieldCount, Err: err}
+	}
+	return faaRecord{columns: columns, fields: fields}, nil
+}
+
+// get returns the value of the column.
+func (r faaRecord) get(c faaColumn) string {
+	return r.fields[r.columns.columns[c]]
+}
@@ -0,0 +1,131 @@
+// Column mapping tests parse FAA-style records whose headers have been reordered, extended or renamed.
+package importer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseRecord_ReorderedColumns tests that fields are read by header name wherever their column is.
+func TestParseRecord_ReorderedColumns(t *testing.T) {
+	header := strings.Split(testCSVHeader, ",")
+	fields := strings.Split(testCSVRow("01-JAN-23", "N12345"), ",")
+	fields[13], fields[25] = "CESSNA", "2" // ACFT_MAKE_NAME, FLT_CRW_INJ_FATAL
+
+	// Reverse the columns and add one the importer does not read.
+	for i, j := 0, len(header)-1; i < j; i, j = i+1, j-1 {
+		header[i], header[j] = header[j], header[i]
+		fields[i], fields[j] = fields[j], fields[i]
+	}
+	header = append([]string{"\ufeffEVENT_ID"}, header...)
+	fields = append([]string{"12345"}, fields...)
+
+	columns, err := newColumnMap(header)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(columns.ignored, []string{"EVENT_ID"}) {
+		t.Errorf("Expected EVENT_ID to be ignored, got %v", columns.ignored)
+	}
+
+	record, err := parseRecord(columns, fields)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if record.Aircraft.RegistrationNumber != "N12345" || record.Aircraft.AircraftMakeName != "CESSNA" {
+		t.Errorf("Expected N12345 made by CESSNA, got %+v", record.Aircraft)
+	}
+	if record.Location.CityName != "AUSTIN" || record.Location.CountryName != "United States" {
+		t.Errorf("Expected AUSTIN, United States, got %+v", record.Location)
+	}
+
+	injuries := make(map[string]int)
+	for _, injury := range record.Injuries {
+		injuries[injury.PersonType+" "+injury.InjurySeverity] = injury.Count
+	}
+	expected := map[string]int{"flight_crew none": 1, "flight_crew fatal": 2}
+	if !reflect.DeepEqual(injuries, expected) {
+		t.Errorf("Expected injuries %v, got %v", expected, injuries)
+	}
+
+	if _, err := parseRecord(columns, fields[1:]); err == nil {
+		t.Error("Expected an error for a record with fewer fields than the header, got nil")
+	}
+}
+
+// TestNewColumnMap_SchemaDrift tests the report for headers missing, renaming or duplicating columns.
+func TestNewColumnMap_SchemaDrift(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  map[string]string // Header names to replace, "" to remove the column
+		expected schemaDriftError
+	}{
+		{
+			name:    "renamed",
+			replace: map[string]string{"REGIST_NBR": "REGIST_NUM"},
+			expected: schemaDriftError{
+				Missing:    []string{"REGIST_NBR"},
+				Renamed:    map[string]string{"REGIST_NBR": "REGIST_NUM"},
+				Unexpected: []string{"REGIST_NUM"},
+			},
+		},
+		{
+			name:    "removed",
+			replace: map[string]string{"PAX_INJ_UNK": ""},
+			expected: schemaDriftError{
+				Missing: []string{"PAX_INJ_UNK"},
+				Renamed: map[string]string{},
+			},
+		},
+		{
+			name:    "duplicate",
+			replace: map[string]string{"FLT_NBR": "FSDO_DESC"},
+			expected: schemaDriftError{
+				Missing:   []string{"FLT_NBR"},
+				Renamed:   map[string]string{},
+				Duplicate: []string{"FSDO_DESC"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var header []string
+		for _, name := range strings.Split(testCSVHeader, ",") {
+			if replacement, ok := tt.replace[name]; ok {
+				name = replacement
+			}
+			if name != "" {
+				header = append(header, name)
+			}
+		}
+
+		_, err := newColumnMap(header)
+		var drift *schemaDriftError
+		if !errors.As(err, &drift) {
+			t.Errorf("%s: expected a schemaDriftError, got %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*drift, tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, *drift)
+		}
+	}
+}
+
+// TestProcessCSV_SchemaDrift tests that a file whose header has drifted is rejected before anything is written.
+func TestProcessCSV_SchemaDrift(t *testing.T) {
+	db := newTestDB(t)
+
+	header := strings.Replace(testCSVHeader, "EVENT_LCL_DATE", "EVENT_LOCAL_DATE", 1)
+	csv := header + "\n" + testCSVRow("01-JAN-23", "N1") + "\n"
+
+	_, err := processCSV(context.Background(), strings.NewReader(csv), db, &fakeGeocoder{}, importOptions{BatchSize: 1})
+	if err == nil || !strings.Contains(err.Error(), "missing column EVENT_LCL_DATE (renamed to EVENT_LOCAL_DATE?)") {
+		t.Errorf("Expected a schema drift report, got %v", err)
+	}
+	if got := countRows(t, db, "Accidents"); got != 0 {
+		t.Errorf("Expected no accidents, got %d", got)
+	}
+}
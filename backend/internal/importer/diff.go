@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// recordDiff describes what importing a record would change.
+type recordDiff struct {
+	Line     int
+	Key      string // Natural key of the accident
+	Outcome  recordOutcome
+	Changes  []fieldChange // Only set for updated records
+	accident int           // ID of the existing accident, if any
+}
+
+// diffReport compares records against the database without writing to it, printing what an import would
+// change. It takes the place of writeBatch in a batchWriter for dry runs.
+type diffReport struct {
+	w io.Writer
+
+	seen     map[int]bool // IDs of the existing accidents found in the source
+	earliest time.Time    // ENTRY_DATE range of the source
+	latest   time.Time
+
+	Disappeared int
+}
+
+// newDiffReport creates a diffReport printing to w.
+func newDiffReport(w io.Writer) *diffReport {
+	return &diffReport{w: w, seen: make(map[int]bool)}
+}
+
+// diffBatch compares the records with the database in a read-only transaction and prints the differences.
+func (d *diffReport) diffBatch(ctx context.Context, db *store.DB, records []*parsedRecord) ([]recordOutcome, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	w, err := newRecordWriter(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	diffs := make([]*recordDiff, 0, len(records))
+	for _, record := range records {
+		diff, err := w.diffRecord(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+
+	// Only report the batch once all of it was compared, as a failed batch is retried one record at a time.
+	outcomes := make([]recordOutcome, len(diffs))
+	for i, diff := range diffs {
+		d.print(diff)
+		outcomes[i] = diff.Outcome
+		if diff.Outcome != outcomeInserted {
+			d.seen[diff.accident] = true
+		}
+
+		entryDate := records[i].Accident.EntryDate
+		if d.earliest.IsZero() || entryDate.Before(d.earliest) {
+			d.earliest = entryDate
+		}
+		if entryDate.After(d.latest) {
+			d.latest = entryDate
+		}
+	}
+	return outcomes, nil
+}
+
+// print writes a line for a new or modified record, followed by one line per changed field.
+func (d *diffReport) print(diff *recordDiff) {
+	switch diff.Outcome {
+	case outcomeInserted:
+		fmt.Fprintf(d.w, "+ line %d: %s\n", diff.Line, diff.Key)
+	case outcomeUpdated:
+		fmt.Fprintf(d.w, "~ line %d: %s\n", diff.Line, diff.Key)
+		for _, change := range diff.Changes {
+			fmt.Fprintf(d.w, "    %s: %q -> %q\n", change.Field, change.Old, change.New)
+		}
+	}
+}
+
+// findDisappeared prints the accidents in the database that are missing from the source. Only accidents
+// within the source's ENTRY_DATE range are considered, so that importing a partial export, such as the
+// FAA's file of recent accidents, does not report every older accident as disappeared.
+func (d *diffReport) findDisappeared(ctx context.Context, db *store.DB) error {
+	if d.latest.IsZero() {
+		return nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT a.id, ac.registration_number, a.event_local_date, a.event_local_time, a.fsdo_description
+		FROM Accidents a
+		JOIN Aircrafts ac ON ac.id = a.aircraft_id
+		WHERE a.entry_date >= ? AND a.entry_date <= ? AND a.source = 'FAA'
+		ORDER BY a.id`, d.earliest.Format(dateLayout), d.latest.Format(dateLayout))
+	if err != nil {
+		return fmt.Errorf("error querying accidents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var accident models.Accident
+		var registration string
+		if err := rows.Scan(&id, &registration, &accident.EventLocalDate, &accident.EventLocalTime, &accident.FSDODescription); err != nil {
+			return fmt.Errorf("error scanning accident: %w", err)
+		}
+		if d.seen[id] {
+			continue
+		}
+		d.Disappeared++
+		fmt.Fprintf(d.w, "- accident %d: %s\n", id, naturalKey(registration, &accident))
+	}
+	return rows.Err()
+}
+
+// naturalKey formats the natural key of an accident for the report.
+func naturalKey(registration string, accident *models.Accident) string {
+	return fmt.Sprintf("%s %s %s %s", registration, accident.EventLocalDate.Format(dateLayout), accident.EventLocalTime, accident.FSDODescription)
+}
+
+// diffRecord compares a record with the database without writing to it.
+func (w *recordWriter) diffRecord(ctx context.Context, record *parsedRecord) (*recordDiff, error) {
+	diff := &recordDiff{Line: record.Line, Key: naturalKey(record.Aircraft.RegistrationNumber, record.Accident)}
+
+	existing, err := w.findAccident(ctx, record.Aircraft.RegistrationNumber, record.Accident)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		diff.Outcome = outcomeInserted
+		return diff, nil
+	}
+	diff.accident = existing.ID
+	diff.Changes = accidentChanges(existing, record.Accident)
+
+	var aircraft models.Aircraft
+	err = w.tx.QueryRowContext(ctx, "SELECT aircraft_make_name, aircraft_model_name, aircraft_operator FROM Aircrafts WHERE id = ?", existing.AircraftID).
+		Scan(&aircraft.AircraftMakeName, &aircraft.AircraftModelName, &aircraft.AircraftOperator)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up aircraft: %w", err)
+	}
+	for _, field := range []fieldChange{
+		{"aircraft_make_name", aircraft.AircraftMakeName, record.Aircraft.AircraftMakeName},
+		{"aircraft_model_name", aircraft.AircraftModelName, record.Aircraft.AircraftModelName},
+		{"aircraft_operator", aircraft.AircraftOperator, record.Aircraft.AircraftOperator},
+	} {
+		if field.Old != field.New {
+			diff.Changes = append(diff.Changes, field)
+		}
+	}
+
+	var location models.Location
+	err = w.tx.QueryRowContext(ctx, "SELECT city_name, state_name, country_name FROM Locations WHERE id = ?", existing.LocationID).Scan(&location.CityName, &location.StateName, &location.CountryName)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error looking up location: %w", err)
+	}
+	if old, place := formatPlace(&location), formatPlace(record.Location); old != place {
+		diff.Changes = append(diff.Changes, fieldChange{Field: "location", Old: old, New: place})
+	}
+
+	injuries, err := w.getInjuries(ctx, existing.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !sameInjuries(injuries, record.Injuries) {
+		diff.Changes = append(diff.Changes, fieldChange{Field: "injuries", Old: formatInjuries(injuries), New: formatInjuries(record.Injuries)})
+	}
+
+	diff.Outcome = outcomeUnchanged
+	if len(diff.Changes) > 0 {
+		diff.Outcome = outcomeUpdated
+	}
+	return diff, nil
+}
+
+// formatPlace formats a location's city, state and country.
+func formatPlace(location *models.Location) string {
+	return fmt.Sprintf("%s, %s, %s", location.CityName, location.StateName, location.CountryName)
+}
+
+// formatInjuries lists injury counts by person type and severity, such as "flight_crew/fatal=1, passengers/none=2".
+func formatInjuries(injuries []*models.Injury) string {
+	parts := make([]string, 0, len(injuries))
+	for _, injury := range injuries {
+		parts = append(parts, fmt.Sprintf("%s/%s=%d", injury.PersonType, injury.InjurySeverity, injury.Count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,67 @@
+// Diff tests compare a new CSV file with a database loaded from an older one.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestProcessCSV_DryRun tests that a dry run reports new, modified and disappeared accidents without writing anything.
+func TestProcessCSV_DryRun(t *testing.T) {
+	db := newTestDB(t)
+
+	old := strings.Join([]string{
+		testCSVHeader,
+		testCSVRow("01-JAN-23", "N1"),
+		testCSVRow("02-JAN-23", "N2"),
+		testCSVRow("03-JAN-23", "N3"),
+		testCSVRow("01-JAN-22", "N9"), // Outside the new file's ENTRY_DATE range
+	}, "\n") + "\n"
+	geocoder := &fakeGeocoder{places: map[string]coordinates{"AUSTIN, Texas, United States": {30.27, -97.74}}}
+	if _, err := processCSV(context.Background(), strings.NewReader(old), db, geocoder, importOptions{BatchSize: 10}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	modified := strings.Replace(testCSVRow("02-JAN-23", "N2"), "AIRCRAFT LANDED HARD.", "AIRCRAFT GROUND LOOPED.", 1)
+	current := strings.Join([]string{
+		testCSVHeader,
+		testCSVRow("01-JAN-23", "N1"),
+		modified,
+		testCSVRow("04-JAN-23", "N4"),
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	diff := newDiffReport(&out)
+	stats, err := processCSV(context.Background(), strings.NewReader(current), db, geocoder, importOptions{BatchSize: 10, Diff: diff})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := diff.findDisappeared(context.Background(), db); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedStats := Stats{Inserted: 1, Updated: 1, Unchanged: 1}
+	if stats != expectedStats || diff.Disappeared != 1 {
+		t.Errorf("Expected stats %+v and 1 disappeared, got %+v and %d", expectedStats, stats, diff.Disappeared)
+	}
+
+	expected := []string{
+		`~ line 3: N2 2023-01-02 10:00:00 FSDO`,
+		`    remark_text: "AIRCRAFT LANDED HARD. AUSTIN, TEXAS." -> "AIRCRAFT GROUND LOOPED. AUSTIN, TEXAS."`,
+		`+ line 4: N4 2023-01-04 10:00:00 FSDO`,
+		`- accident 3: N3 2023-01-03 10:00:00 FSDO`,
+	}
+	if got := strings.TrimSpace(out.String()); got != strings.Join(expected, "\n") {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", strings.Join(expected, "\n"), got)
+	}
+
+	if got := countRows(t, db, "Accidents"); got != 4 {
+		t.Errorf("Expected the dry run to leave 4 accidents, got %d", got)
+	}
+	var remark string
+	if err := db.QueryRow("SELECT remark_text FROM Accidents WHERE id = 2").Scan(&remark); err != nil || !strings.HasPrefix(remark, "AIRCRAFT LANDED HARD.") {
+		t.Errorf("Expected the remark to be unchanged, got %q, %v", remark, err)
+	}
+}
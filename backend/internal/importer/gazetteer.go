@@ -0,0 +1,411 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// gazetteerGeocoder resolves places offline from gazetteer files loaded at startup.
+// It reads GeoNames exports (e.g. US.txt or allCountries.txt) and Census Bureau place gazetteers
+// (e.g. 2023_Gaz_place_national.txt), matching city names after normalizing common FAA spelling
+// variants and, failing that, to the closest spelling within the state. Places whose city is unknown
+// resolve to the centroid of their state or country when the gazetteer has one.
+type gazetteerGeocoder struct {
+	places      map[string]map[string]gazetteerPlace // Region key ("US/TX") → normalized city name → place
+	regions     map[string]coordinates               // Region key → centroid of the state or province
+	regionCodes map[string]map[string]string         // Country code → normalized region name → region code
+	countries   map[string]coordinates               // Country code → centroid of the country
+	countryCode map[string]string                    // Normalized country name → country code
+}
+
+// gazetteerPlace is a populated place in a gazetteer.
+type gazetteerPlace struct {
+	coordinates
+	population int // Used to choose between places with the same name
+}
+
+// newGazetteerGeocoder loads the gazetteer files, detecting the format of each from its first line.
+func newGazetteerGeocoder(paths ...string) (*gazetteerGeocoder, error) {
+	g := &gazetteerGeocoder{
+		places:      make(map[string]map[string]gazetteerPlace),
+		regions:     make(map[string]coordinates),
+		regionCodes: make(map[string]map[string]string),
+		countries:   make(map[string]coordinates),
+		countryCode: map[string]string{"UNITED STATES": "US", "UNITED STATES OF AMERICA": "US", "USA": "US", "US": "US"},
+	}
+	for code, name := range usStates {
+		g.addRegionName("US", code, name)
+		g.addRegionName("US", code, code)
+	}
+
+	for _, path := range paths {
+		if err := g.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// load reads a GeoNames or Census gazetteer file.
+func (g *gazetteerGeocoder) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening gazetteer: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // GeoNames alternate names can make for long lines
+
+	var header []string
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Split(scanner.Text(), "\t")
+
+		if lineNumber == 1 && fields[0] == "USPS" {
+			header = fields
+			continue
+		}
+
+		if header != nil {
+			err = g.addCensusPlace(header, fields)
+		} else {
+			err = g.addGeoNamesEntry(fields)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading gazetteer %s line %d: %w", path, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading gazetteer %s: %w", path, err)
+	}
+	return nil
+}
+
+// addGeoNamesEntry adds a row of a GeoNames export: populated places (feature class P),
+// first-order administrative divisions (ADM1) and countries (PCL*).
+func (g *gazetteerGeocoder) addGeoNamesEntry(fields []string) error {
+	if len(fields) < 15 {
+		return fmt.Errorf("expected at least 15 columns, got %d", len(fields))
+	}
+
+	name, asciiName, alternateNames := fields[1], fields[2], fields[3]
+	featureClass, featureCode := fields[6], fields[7]
+	countryCode, admin1 := fields[8], fields[10]
+
+	isPlace := featureClass == "P"
+	isRegion := featureClass == "A" && featureCode == "ADM1"
+	isCountry := featureClass == "A" && strings.HasPrefix(featureCode, "PCL")
+	if !isPlace && !isRegion && !isCountry {
+		return nil
+	}
+
+	coords, err := parseCoordinates(fields[4], fields[5])
+	if err != nil {
+		return err
+	}
+	population, _ := strconv.Atoi(fields[14])
+
+	names := []string{name, asciiName}
+	if alternateNames != "" {
+		names = append(names, strings.Split(alternateNames, ",")...)
+	}
+
+	switch {
+	case isPlace:
+		for _, n := range names {
+			g.addPlace(countryCode, admin1, normalizeCityName(n), gazetteerPlace{coords, population})
+		}
+	case isRegion:
+		g.regions[regionKey(countryCode, admin1)] = coords
+		for _, n := range names {
+			g.addRegionName(countryCode, admin1, n)
+		}
+	case isCountry:
+		g.countries[countryCode] = coords
+		for _, n := range append(names, countryCode) {
+			if key := normalizeName(n); key != "" {
+				if _, exists := g.countryCode[key]; !exists {
+					g.countryCode[key] = countryCode
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// censusPlaceSuffixes are the legal/statistical area descriptions that end Census place names, e.g. "Austin city".
+var censusPlaceSuffixes = []string{"CITY", "TOWN", "VILLAGE", "CDP", "BOROUGH", "MUNICIPALITY", "COMUNIDAD", "ZONA URBANA"}
+
+// addCensusPlace adds a row of a Census Bureau place gazetteer, reading columns by header name.
+func (g *gazetteerGeocoder) addCensusPlace(header, fields []string) error {
+	column := func(name string) string {
+		for i, h := range header {
+			if strings.TrimSpace(h) == name && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+		}
+		return ""
+	}
+
+	coords, err := parseCoordinates(column("INTPTLAT"), column("INTPTLONG"))
+	if err != nil {
+		return err
+	}
+
+	name := normalizeCityName(column("NAME"))
+	for _, suffix := range censusPlaceSuffixes {
+		if trimmed := strings.TrimSuffix(name, " "+suffix); trimmed != name {
+			name = trimmed
+			break
+		}
+	}
+
+	g.addPlace("US", column("USPS"), name, gazetteerPlace{coordinates: coords})
+	return nil
+}
+
+// addPlace indexes a place by name, keeping the most populous place when names collide within a region.
+func (g *gazetteerGeocoder) addPlace(countryCode, regionCode, name string, place gazetteerPlace) {
+	if name == "" {
+		return
+	}
+	key := regionKey(countryCode, regionCode)
+	if g.places[key] == nil {
+		g.places[key] = make(map[string]gazetteerPlace)
+	}
+	if existing, ok := g.places[key][name]; !ok || place.population > existing.population {
+		g.places[key][name] = place
+	}
+}
+
+// addRegionName indexes the code of a state or province by name.
+func (g *gazetteerGeocoder) addRegionName(countryCode, regionCode, name string) {
+	if g.regionCodes[countryCode] == nil {
+		g.regionCodes[countryCode] = make(map[string]string)
+	}
+	if key := normalizeName(name); key != "" {
+		g.regionCodes[countryCode][key] = regionCode
+	}
+}
+
+// Geocode resolves "city, state, country", "state, country" or "country".
+// A missing country is assumed to be the United States, as in the FAA data.
+func (g *gazetteerGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	parts := strings.Split(normalizePlace(place), ", ")
+	if len(parts) == 1 && parts[0] == "" {
+		return coordinates{}, errPlaceNotFound
+	}
+
+	// Read the components from the right, since empty ones are dropped by normalizePlace.
+	countryCode := "US"
+	if code, ok := g.countryCode[normalizeName(parts[len(parts)-1])]; ok {
+		countryCode = code
+		parts = parts[:len(parts)-1]
+	} else if len(parts) >= 3 {
+		// A country the gazetteer does not cover; don't mistake it for a US city.
+		return coordinates{}, errPlaceNotFound
+	}
+
+	var regionCode string
+	if len(parts) > 0 {
+		if code, ok := g.regionCodes[countryCode][normalizeName(parts[len(parts)-1])]; ok {
+			regionCode = code
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	if len(parts) > 0 {
+		if coords, ok := g.findCity(countryCode, regionCode, normalizeCityName(strings.Join(parts, " "))); ok {
+			return coords, nil
+		}
+	}
+
+	if regionCode != "" {
+		if coords, ok := g.regions[regionKey(countryCode, regionCode)]; ok {
+			return coords, nil
+		}
+	}
+	if coords, ok := g.countries[countryCode]; ok {
+		return coords, nil
+	}
+	return coordinates{}, errPlaceNotFound
+}
+
+// findCity looks a city up by exact normalized name, then by the closest spelling within the region.
+// Without a region the city must match exactly, choosing the most populous match in the country.
+func (g *gazetteerGeocoder) findCity(countryCode, regionCode, name string) (coordinates, bool) {
+	if regionCode == "" {
+		var best *gazetteerPlace
+		prefix := countryCode + "/"
+		for key, places := range g.places {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if place, ok := places[name]; ok && (best == nil || place.population > best.population) {
+				best = &place
+			}
+		}
+		if best == nil {
+			return coordinates{}, false
+		}
+		return best.coordinates, true
+	}
+
+	places := g.places[regionKey(countryCode, regionCode)]
+	if place, ok := places[name]; ok {
+		return place.coordinates, true
+	}
+
+	// Allow one typo in short names and two in longer ones, preferring the closest, then the most populous,
+	// then the alphabetically first match so that results do not depend on map order.
+	maxDistance := 1
+	if len(name) > 8 {
+		maxDistance = 2
+	}
+	var best gazetteerPlace
+	bestName, bestDistance := "", maxDistance+1
+	for candidate, place := range places {
+		distance := levenshtein(name, candidate, maxDistance)
+		if distance > maxDistance {
+			continue
+		}
+		if distance < bestDistance ||
+			(distance == bestDistance && place.population > best.population) ||
+			(distance == bestDistance && place.population == best.population && candidate < bestName) {
+			best, bestName, bestDistance = place, candidate, distance
+		}
+	}
+	if bestDistance > maxDistance {
+		return coordinates{}, false
+	}
+	return best.coordinates, true
+}
+
+// regionKey identifies a state or province within a country.
+func regionKey(countryCode, regionCode string) string {
+	return countryCode + "/" + regionCode
+}
+
+// parseCoordinates parses a latitude and longitude.
+func parseCoordinates(latitude, longitude string) (coordinates, error) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+	if err != nil {
+		return coordinates{}, fmt.Errorf("invalid latitude %q", latitude)
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+	if err != nil {
+		return coordinates{}, fmt.Errorf("invalid longitude %q", longitude)
+	}
+	return coordinates{Latitude: lat, Longitude: lng}, nil
+}
+
+// normalizeName uppercases a name and reduces punctuation and runs of whitespace to single spaces.
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '\'', '’':
+			return -1
+		case '.', '-', '/', '(', ')':
+			return ' '
+		}
+		return r
+	}, strings.ToUpper(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// cityAbbreviations expands the abbreviations found in FAA city names.
+var cityAbbreviations = map[string]string{
+	"ST":   "SAINT",
+	"STE":  "SAINTE",
+	"FT":   "FORT",
+	"MT":   "MOUNT",
+	"PT":   "POINT",
+	"N":    "NORTH",
+	"S":    "SOUTH",
+	"E":    "EAST",
+	"W":    "WEST",
+	"HTS":  "HEIGHTS",
+	"SPGS": "SPRINGS",
+	"SPG":  "SPRINGS",
+	"BCH":  "BEACH",
+	"LK":   "LAKE",
+	"CTR":  "CENTER",
+	"TWP":  "TOWNSHIP",
+}
+
+// normalizeCityName normalizes a city name and expands abbreviations, so that e.g.
+// "ST. LOUIS", "St Louis" and "Saint Louis" all become "SAINT LOUIS".
+func normalizeCityName(name string) string {
+	words := strings.Fields(normalizeName(name))
+	for i, word := range words {
+		if expanded, ok := cityAbbreviations[word]; ok {
+			words[i] = expanded
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// levenshtein returns the edit distance between a and b, or max+1 once it is known to exceed max.
+func levenshtein(a, b string, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// min3 returns the smallest of a, b and c.
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// usStates maps the USPS codes of US states and territories to their names.
+var usStates = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+	"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia", "FL": "Florida",
+	"GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois", "IN": "Indiana",
+	"IA": "Iowa", "KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana", "ME": "Maine",
+	"MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota", "MS": "Mississippi",
+	"MO": "Missouri", "MT": "Montana", "NE": "Nebraska", "NV": "Nevada", "NH": "New Hampshire",
+	"NJ": "New Jersey", "NM": "New Mexico", "NY": "New York", "NC": "North Carolina", "ND": "North Dakota",
+	"OH": "Ohio", "OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island",
+	"SC": "South Carolina", "SD": "South Dakota", "TN": "Tennessee", "TX": "Texas", "UT": "Utah",
+	"VT": "Vermont", "VA": "Virginia", "WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin",
+	"WY": "Wyoming", "PR": "Puerto Rico", "GU": "Guam", "VI": "Virgin Islands", "AS": "American Samoa",
+	"MP": "Northern Mariana Islands",
+}
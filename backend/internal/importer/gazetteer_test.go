@@ -0,0 +1,86 @@
+// Gazetteer tests load small GeoNames and Census samples from testdata.
+package importer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestGazetteerGeocoder tests resolving FAA places offline, including spelling variants and centroid fallbacks.
+func TestGazetteerGeocoder(t *testing.T) {
+	geocoder, err := newGazetteerGeocoder("testdata/geonames_sample.txt", "testdata/census_sample.txt")
+	if err != nil {
+		t.Fatalf("Expected no error loading gazetteers, got %v", err)
+	}
+
+	tests := []struct {
+		place    string
+		expected coordinates
+		err      error
+	}{
+		{"AUSTIN, Texas, United States", coordinates{30.26715, -97.74306}, nil},
+		{"AUSTIN, Minnesota, United States", coordinates{43.66663, -92.97464}, nil},
+		{"ST. LOUIS, Missouri, United States", coordinates{38.62727, -90.19789}, nil},
+		{"SAN ANTONOI, Texas, United States", coordinates{29.42412, -98.49363}, nil},  // Misspelled
+		{"FT WORTH, Texas, United States", coordinates{32.781726, -97.347412}, nil},   // Census "Fort Worth city"
+		{"KANSAS CITY, MO, United States", coordinates{39.125124, -94.551139}, nil},   // Census "Kansas City city"
+		{"DENVER, , United States", coordinates{39.73915, -104.9847}, nil},            // No state
+		{"NOWHEREVILLE, Texas, United States", coordinates{31.25044, -99.25061}, nil}, // State centroid
+		{"NOWHEREVILLE, Colorado, United States", coordinates{39.76, -98.5}, nil},     // No Colorado centroid, so the country's
+		{"TORONTO, Ontario, Canada", coordinates{}, errPlaceNotFound},                 // Country not in the gazetteer
+		{", , ", coordinates{}, errPlaceNotFound},
+	}
+
+	for _, tt := range tests {
+		coords, err := geocoder.Geocode(context.Background(), tt.place)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Geocode(%q) returned error %v, want %v", tt.place, err, tt.err)
+			continue
+		}
+		if coords != tt.expected {
+			t.Errorf("Geocode(%q) = %v, want %v", tt.place, coords, tt.expected)
+		}
+	}
+}
+
+// TestNormalizeCityName tests that FAA spelling variants normalize to the gazetteer spelling.
+func TestNormalizeCityName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"ST. LOUIS", "SAINT LOUIS"},
+		{"Ft Lauderdale", "FORT LAUDERDALE"},
+		{"COEUR D'ALENE", "COEUR DALENE"},
+		{"WINSTON-SALEM", "WINSTON SALEM"},
+		{"N  LAS VEGAS", "NORTH LAS VEGAS"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCityName(tt.name); got != tt.expected {
+			t.Errorf("normalizeCityName(%q) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// TestLevenshtein tests edit distances and the early exit once the maximum is exceeded.
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		max      int
+		expected int
+	}{
+		{"DENVER", "DENVER", 2, 0},
+		{"DENVR", "DENVER", 2, 1},
+		{"SAN ANTONOI", "SAN ANTONIO", 2, 2},
+		{"AUSTIN", "BOSTON", 1, 2},
+		{"A", "ABCDEF", 2, 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b, tt.max); got != tt.expected {
+			t.Errorf("levenshtein(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// errPlaceNotFound is returned by geocoders when a place has no known coordinates.
+var errPlaceNotFound = errors.New("place not found")
+
+// coordinates is a geocoded latitude and longitude.
+type coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Geocoder resolves a place such as "AUSTIN, TEXAS, UNITED STATES" to coordinates.
+type Geocoder interface {
+	Geocode(ctx context.Context, place string) (coordinates, error)
+}
+
+// normalizePlace canonicalizes a place so that spellings differing only in case, whitespace
+// or empty components share a cache entry.
+func normalizePlace(place string) string {
+	var parts []string
+	for _, part := range strings.Split(place, ",") {
+		part = strings.Join(strings.Fields(part), " ")
+		if part != "" {
+			parts = append(parts, strings.ToUpper(part))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// googleRequestTimeout bounds a single Geocoding API request, so a stalled connection cannot hold up an
+// import that is finishing the records already read.
+const googleRequestTimeout = 15 * time.Second
+
+// googleGeocoder resolves places with the Google Maps Geocoding API.
+type googleGeocoder struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// newGoogleGeocoder creates a geocoder for the Google Maps Geocoding API.
+func newGoogleGeocoder(apiKey string) *googleGeocoder {
+	return &googleGeocoder{
+		apiKey:  apiKey,
+		baseURL: "https://maps.googleapis.com/maps/api/geocode/json",
+		client:  &http.Client{Timeout: googleRequestTimeout},
+	}
+}
+
+// Geocode retrieves the coordinates of the first result for the place.
+func (g *googleGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	requestURL := fmt.Sprintf("%s?address=%s&key=%s", g.baseURL, url.QueryEscape(place), url.QueryEscape(g.apiKey))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return coordinates{}, err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		// The error includes the request URL; drop the API key so it is not logged or quarantined.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = strings.Replace(urlErr.URL, "key="+url.QueryEscape(g.apiKey), "key=REDACTED", 1)
+		}
+		return coordinates{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return coordinates{}, fmt.Errorf("geocoding request failed with status %s", resp.Status)
+	}
+
+	var geoResp models.GeoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geoResp); err != nil {
+		return coordinates{}, err
+	}
+
+	switch geoResp.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return coordinates{}, errPlaceNotFound
+	default:
+		return coordinates{}, fmt.Errorf("geocoding failed with status %s: %s", geoResp.Status, geoResp.ErrorMessage)
+	}
+	if len(geoResp.Results) == 0 {
+		return coordinates{}, errPlaceNotFound
+	}
+
+	location := geoResp.Results[0].Geometry.Location
+	return coordinates{Latitude: location.Lat, Longitude: location.Lng}, nil
+}
+
+// geocodeCacheEntry is a cached geocoding result. Places that were not found are cached too,
+// so that they are not looked up again on every run.
+type geocodeCacheEntry struct {
+	Found       bool `json:"found"`
+	coordinates      // Only set when Found
+}
+
+// rateLimitedGeocoder spaces out the lookups of another geocoder, however many goroutines call it,
+// to stay within the provider's queries-per-second quota.
+type rateLimitedGeocoder struct {
+	next     Geocoder
+	interval time.Duration
+
+	mu       sync.Mutex
+	nextSlot time.Time
+}
+
+// newRateLimitedGeocoder wraps next so that it is called at most perSecond times a second.
+func newRateLimitedGeocoder(next Geocoder, perSecond float64) *rateLimitedGeocoder {
+	return &rateLimitedGeocoder{next: next, interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Geocode waits for the next free slot, or until the context is cancelled, and then looks the place up.
+func (g *rateLimitedGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	g.mu.Lock()
+	slot := time.Now()
+	if g.nextSlot.After(slot) {
+		slot = g.nextSlot
+	}
+	g.nextSlot = slot.Add(g.interval)
+	g.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return coordinates{}, ctx.Err()
+		}
+	}
+	return g.next.Geocode(ctx, place)
+}
+
+// cachingGeocoder remembers the results of another geocoder in a JSON file, keyed by normalized place.
+// Failed lookups other than errPlaceNotFound are not cached, so they are retried on the next run.
+// Concurrent lookups of the same place share a single call to the wrapped geocoder.
+type cachingGeocoder struct {
+	next   Geocoder
+	path   string
+	lookup singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]geocodeCacheEntry
+	dirty   bool
+}
+
+// newCachingGeocoder wraps next with a cache persisted at path, loading any existing entries.
+func newCachingGeocoder(next Geocoder, path string) (*cachingGeocoder, error) {
+	g := &cachingGeocoder{next: next, path: path, entries: make(map[string]geocodeCacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading geocode cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &g.entries); err != nil {
+		return nil, fmt.Errorf("error parsing geocode cache %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Geocode returns the cached result for the place, looking it up with the wrapped geocoder on a miss.
+func (g *cachingGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	key := normalizePlace(place)
+
+	g.mu.Lock()
+	entry, ok := g.entries[key]
+	g.mu.Unlock()
+	if ok {
+		if !entry.Found {
+			return coordinates{}, errPlaceNotFound
+		}
+		return entry.coordinates, nil
+	}
+
+	result, err, _ := g.lookup.Do(key, func() (interface{}, error) {
+		coords, err := g.next.Geocode(ctx, key)
+		if err != nil && !errors.Is(err, errPlaceNotFound) {
+			return coordinates{}, err
+		}
+
+		g.mu.Lock()
+		g.entries[key] = geocodeCacheEntry{Found: err == nil, coordinates: coords}
+		g.dirty = true
+		g.mu.Unlock()
+
+		return coords, err
+	})
+	return result.(coordinates), err
+}
+
+// Save writes the cache to its file if it changed, replacing the file atomically.
+func (g *cachingGeocoder) Save() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(g.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(g.path), filepath.Base(g.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating geocode cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing geocode cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing geocode cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), g.path); err != nil {
+		return fmt.Errorf("error replacing geocode cache: %w", err)
+	}
+
+	g.dirty = false
+	return nil
+}
+
+// geocodeLocation sets the coordinates of the location. When geocoding fails the location is kept
+// without coordinates and flagged, rather than rejecting the record, and the error is returned.
+func geocodeLocation(ctx context.Context, geocoder Geocoder, location *models.Location) error {
+	place := fmt.Sprintf("%s, %s, %s", location.CityName, location.StateName, location.CountryName)
+
+	coords, err := geocoder.Geocode(ctx, place)
+	if err != nil {
+		log.Printf("Failed to geocode %s, storing it without coordinates: %v", place, err)
+		location.Latitude, location.Longitude = nil, nil
+		location.GeocodeFailed = true
+		return err
+	}
+
+	location.Latitude, location.Longitude = &coords.Latitude, &coords.Longitude
+	location.GeocodeFailed = false
+	return nil
+}
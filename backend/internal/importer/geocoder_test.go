@@ -0,0 +1,224 @@
+// Geocoder tests use fake geocoders and an httptest server in place of the Google Maps API.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// fakeGeocoder resolves places from a map and counts its lookups.
+type fakeGeocoder struct {
+	places map[string]coordinates
+	err    error // Returned for every lookup when set
+
+	mu    sync.Mutex
+	calls int
+}
+
+// Geocode returns the place's coordinates from the map.
+func (f *fakeGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if f.err != nil {
+		return coordinates{}, f.err
+	}
+	coords, ok := f.places[place]
+	if !ok {
+		return coordinates{}, errPlaceNotFound
+	}
+	return coords, nil
+}
+
+// TestNormalizePlace tests that places differing in case, whitespace or empty components normalize alike.
+func TestNormalizePlace(t *testing.T) {
+	tests := []struct {
+		place    string
+		expected string
+	}{
+		{"Austin, Texas, United States", "AUSTIN, TEXAS, UNITED STATES"},
+		{"  san   antonio ,texas,  ", "SAN ANTONIO, TEXAS"},
+		{", , ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizePlace(tt.place); got != tt.expected {
+			t.Errorf("normalizePlace(%q) = %q, want %q", tt.place, got, tt.expected)
+		}
+	}
+}
+
+// TestGoogleGeocoder tests mapping Geocoding API responses to coordinates and errors.
+func TestGoogleGeocoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("address") {
+		case "AUSTIN, TEXAS":
+			fmt.Fprint(w, `{"status": "OK", "results": [{"geometry": {"location": {"lat": 30.27, "lng": -97.74}}}]}`)
+		case "NOWHERE":
+			fmt.Fprint(w, `{"status": "ZERO_RESULTS", "results": []}`)
+		default:
+			fmt.Fprint(w, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`)
+		}
+	}))
+	defer server.Close()
+
+	geocoder := newGoogleGeocoder("key")
+	geocoder.baseURL = server.URL
+
+	coords, err := geocoder.Geocode(context.Background(), "AUSTIN, TEXAS")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if coords.Latitude != 30.27 || coords.Longitude != -97.74 {
+		t.Errorf("Expected coordinates 30.27, -97.74, got %v", coords)
+	}
+
+	if _, err := geocoder.Geocode(context.Background(), "NOWHERE"); !errors.Is(err, errPlaceNotFound) {
+		t.Errorf("Expected errPlaceNotFound, got %v", err)
+	}
+
+	if _, err := geocoder.Geocode(context.Background(), "DENIED"); err == nil || errors.Is(err, errPlaceNotFound) {
+		t.Errorf("Expected a request error, got %v", err)
+	}
+
+	server.Close()
+	if _, err := geocoder.Geocode(context.Background(), "AUSTIN, TEXAS"); err == nil || strings.Contains(err.Error(), "key=key") {
+		t.Errorf("Expected a connection error without the API key, got %v", err)
+	}
+}
+
+// TestCachingGeocoder tests that results are cached by normalized place and persisted between runs,
+// while transient failures are retried.
+func TestCachingGeocoder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode_cache.json")
+	next := &fakeGeocoder{places: map[string]coordinates{"AUSTIN, TEXAS": {30.27, -97.74}}}
+
+	cache, err := newCachingGeocoder(next, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, place := range []string{"Austin, Texas", "AUSTIN ,  TEXAS"} {
+		if coords, err := cache.Geocode(context.Background(), place); err != nil || coords.Latitude != 30.27 {
+			t.Errorf("Expected Austin coordinates for %q, got %v, %v", place, coords, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Geocode(context.Background(), "Nowhere"); !errors.Is(err, errPlaceNotFound) {
+			t.Errorf("Expected errPlaceNotFound, got %v", err)
+		}
+	}
+	if next.calls != 2 {
+		t.Errorf("Expected 2 lookups, got %d", next.calls)
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Expected no error saving cache, got %v", err)
+	}
+
+	// A new run loads the saved entries and does not look them up again.
+	failing := &fakeGeocoder{err: errors.New("connection refused")}
+	cache, err = newCachingGeocoder(failing, path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if coords, err := cache.Geocode(context.Background(), "austin, texas"); err != nil || coords.Longitude != -97.74 {
+		t.Errorf("Expected cached Austin coordinates, got %v, %v", coords, err)
+	}
+	if _, err := cache.Geocode(context.Background(), "Nowhere"); !errors.Is(err, errPlaceNotFound) {
+		t.Errorf("Expected cached errPlaceNotFound, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Geocode(context.Background(), "Denver, Colorado"); err == nil {
+			t.Error("Expected the lookup error, got nil")
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("Expected transient failures to be retried, got %d lookups", failing.calls)
+	}
+}
+
+// TestRateLimitedGeocoder tests that concurrent lookups are spaced out and that waiting honours cancellation.
+func TestRateLimitedGeocoder(t *testing.T) {
+	next := &fakeGeocoder{}
+	geocoder := newRateLimitedGeocoder(next, 50) // One lookup every 20ms
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			geocoder.Geocode(context.Background(), "Nowhere")
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected 5 lookups to take at least 80ms, took %s", elapsed)
+	}
+	if next.calls != 5 {
+		t.Errorf("Expected 5 lookups, got %d", next.calls)
+	}
+
+	slow := newRateLimitedGeocoder(next, 0.01) // One lookup every 100s
+	slow.Geocode(context.Background(), "Nowhere")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := slow.Geocode(ctx, "Nowhere"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while waiting, got %v", err)
+	}
+}
+
+// TestGeocodeLocation_Fallback tests that a failed lookup flags the location instead of rejecting it,
+// and that re-importing it does not clear coordinates resolved earlier.
+func TestGeocodeLocation_Fallback(t *testing.T) {
+	db := newTestDB(t)
+
+	newLocation := func() *models.Location {
+		return &models.Location{CityName: "AUSTIN", StateName: "Texas", CountryName: "United States"}
+	}
+
+	location := newLocation()
+	geocodeLocation(context.Background(), &fakeGeocoder{err: errors.New("quota exceeded")}, location)
+	if !location.GeocodeFailed || location.Latitude != nil || location.Longitude != nil {
+		t.Errorf("Expected flagged location without coordinates, got %+v", location)
+	}
+
+	resolved := newLocation()
+	geocodeLocation(context.Background(), &fakeGeocoder{places: map[string]coordinates{"AUSTIN, Texas, United States": {30.27, -97.74}}}, resolved)
+	if resolved.GeocodeFailed || resolved.Latitude == nil || *resolved.Latitude != 30.27 {
+		t.Errorf("Expected resolved location, got %+v", resolved)
+	}
+
+	// Import the place unresolved, then resolved, then unresolved again.
+	for _, loc := range []*models.Location{location, resolved, location} {
+		record := &parsedRecord{
+			Aircraft: &models.Aircraft{RegistrationNumber: "N12345"},
+			Location: loc,
+			Accident: &models.Accident{EventLocalTime: "10:00:00", Source: store.SourceFAA},
+		}
+		if _, err := writeBatch(context.Background(), db, []*parsedRecord{record}); err != nil {
+			t.Fatalf("Expected no error writing record, got %v", err)
+		}
+	}
+
+	var latitude *float64
+	var failed bool
+	if err := db.QueryRow("SELECT latitude, geocode_failed FROM Locations").Scan(&latitude, &failed); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if latitude == nil || *latitude != 30.27 || failed {
+		t.Errorf("Expected resolved coordinates to be kept, got latitude %v and geocode_failed %t", latitude, failed)
+	}
+}
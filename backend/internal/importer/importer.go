@@ -0,0 +1,362 @@
+// Package importer loads FAA accident and incident CSV files into the database, geocoding their locations,
+// quarantining rejected records and recording each import as an ingestion run.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// Config configures an import.
+type Config struct {
+	BatchSize        int           // Records written per transaction
+	BufferSize       int           // Capacity of the channels between pipeline stages
+	ReorderWindow    int           // Records buffered to write them in descending ENTRY_DATE order, 0 to keep file order
+	Workers          int           // Records geocoded concurrently
+	ProgressInterval time.Duration // How often progress is logged, 0 to disable
+
+	Geocoder         string   // Geocoder to resolve places with: google or gazetteer
+	GoogleMapsAPIKey string   // Key for the Google geocoder
+	GeocodeRate      float64  // Maximum Google geocoding requests per second, 0 for no limit
+	GeocodeCachePath string   // File caching places geocoded with Google, empty to disable
+	GazetteerPaths   []string // GeoNames or Census gazetteer files for the gazetteer geocoder
+
+	QuarantinePath string  // File listing rejected records and records imported without coordinates, empty to disable
+	MaxRejected    float64 // Fraction of records that may be rejected before the import fails
+
+	DryRun     bool      // Report what the import would change instead of writing
+	DiffOutput io.Writer // Receives the dry run report, os.Stdout if nil
+	Force      bool      // Import the file even if it was already imported successfully
+	SourceURL  string    // URL the file was downloaded from, recorded with the ingestion run
+}
+
+// RegisterFlags defines command line flags for the import settings shared by the importer and the ingestion daemon.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.BatchSize, "batch-size", 100, "number of records written per transaction")
+	fs.IntVar(&c.BufferSize, "buffer", 1000, "number of records buffered between pipeline stages")
+	fs.IntVar(&c.ReorderWindow, "reorder-window", 10000, "number of records buffered to write them newest ENTRY_DATE first, 0 to keep file order")
+	fs.IntVar(&c.Workers, "workers", 8, "number of records geocoded concurrently")
+	fs.DurationVar(&c.ProgressInterval, "progress", 10*time.Second, "interval between progress reports, 0 to disable")
+	fs.StringVar(&c.Geocoder, "geocoder", "google", "geocoder to resolve places with: google or gazetteer")
+	fs.Func("gazetteer", "comma separated GeoNames or Census gazetteer files for the gazetteer geocoder", func(paths string) error {
+		c.GazetteerPaths = strings.Split(paths, ",")
+		return nil
+	})
+	fs.StringVar(&c.GeocodeCachePath, "geocode-cache", "geocode_cache.json", "file caching places geocoded with Google, empty to disable")
+	fs.Float64Var(&c.GeocodeRate, "geocode-rate", 40, "maximum Google geocoding requests per second, 0 for no limit")
+	fs.StringVar(&c.QuarantinePath, "quarantine", "quarantine.csv", "file listing rejected records and records imported without coordinates, empty to disable")
+	fs.Float64Var(&c.MaxRejected, "max-rejected", 0.05, "fraction of records that may be rejected before the import fails")
+}
+
+// Result describes a completed, failed or skipped import of a file.
+type Result struct {
+	Name        string // Name of the imported source
+	Stats       Stats
+	Run         *models.IngestionRun // Run recorded for the import, nil for dry runs and skipped files
+	Skipped     *models.IngestionRun // Earlier run that imported the same file, when the import was skipped
+	Disappeared int                  // Accidents missing from the file, counted on dry runs
+}
+
+// Run imports a single source. See RunAll.
+func Run(ctx context.Context, db *store.DB, src Source, cfg Config) (*Result, error) {
+	results, err := RunAll(ctx, db, []Source{src}, cfg)
+	if len(results) == 0 {
+		return nil, err
+	}
+	return results[0], err
+}
+
+// RunAll imports the sources oldest first, by the newest ENTRY_DATE in each, so records from later
+// exports update those from earlier ones. Every file is read once before anything is written, to order them and
+// to refuse the whole import when one cannot be read or does not match its checksum. Each file is recorded as its
+// own ingestion run, and a file whose SHA-256 matches a successful run is skipped unless cfg.Force is set.
+// Standard input is streamed into the import after the files, hashed as it is read and never skipped.
+//
+// The import stops at the first file that fails, returning the results of the files imported so far. Cancelling
+// ctx stops reading the current file and lets the records already read be written, so the import ends on a
+// committed batch; the error then wraps context.Canceled. Dry runs compare each file with the database on its own.
+func RunAll(ctx context.Context, db *store.DB, sources []Source, cfg Config) ([]*Result, error) {
+	scanned := make([]*scannedSource, 0, len(sources))
+	for _, src := range sources {
+		s, err := scanSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", src.Name, err)
+		}
+		scanned = append(scanned, s)
+	}
+	sortChronologically(scanned)
+
+	geocoder, cache, err := newGeocoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	q, closeQuarantine, err := openQuarantine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeQuarantine()
+
+	var results []*Result
+	var total Stats
+	for _, src := range scanned {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("import stopped before %s: %w", src.Name, ctx.Err())
+			break
+		}
+		if len(scanned) > 1 {
+			log.Printf("Importing %s", src.Name)
+		}
+		q.setFile(src.Name)
+
+		var result *Result
+		result, err = runSource(ctx, db, src, geocoder, q, cfg, len(scanned) > 1)
+		results = append(results, result)
+		total.Inserted += result.Stats.Inserted
+		total.Updated += result.Stats.Updated
+		total.Unchanged += result.Stats.Unchanged
+		total.Failed += result.Stats.Failed
+		if err != nil {
+			err = fmt.Errorf("failed to import %s: %w", src.Name, err)
+			break
+		}
+	}
+
+	if len(scanned) > 1 {
+		log.Printf("Processed %d of %d files: %s", len(results), len(scanned), total)
+	}
+	if summary := q.Summary(); summary != "" {
+		log.Printf("Quarantined records: %s", summary)
+	}
+	if err := q.Flush(); err != nil {
+		log.Printf("Failed to write quarantine file: %v", err)
+	}
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			log.Printf("Failed to save geocode cache: %v", err)
+		}
+	}
+	return results, err
+}
+
+// runSource imports a scanned source, recording it as an ingestion run unless it is a dry run.
+// Dry run reports are headed by the source's name when heading is set.
+func runSource(ctx context.Context, db *store.DB, src *scannedSource, geocoder Geocoder, q *quarantine, cfg Config, heading bool) (*Result, error) {
+	result := &Result{Name: src.Name}
+	if !src.streamed {
+		previous, err := previousImport(db, src.Name, src.SHA256, cfg)
+		if err != nil || previous != nil {
+			result.Skipped = previous
+			return result, err
+		}
+	}
+
+	file, err := src.Open()
+	if err != nil {
+		return result, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Hash standard input as it is imported, as it cannot be read beforehand
+	var r io.Reader = file
+	hash := sha256.New()
+	if src.streamed {
+		r = io.TeeReader(file, hash)
+	}
+
+	opts := importOptions{
+		BatchSize:        cfg.BatchSize,
+		BufferSize:       cfg.BufferSize,
+		ReorderWindow:    cfg.ReorderWindow,
+		Workers:          cfg.Workers,
+		ProgressInterval: cfg.ProgressInterval,
+		Quarantine:       q,
+	}
+
+	// Record the import as an ingestion run, or only compare the file with the database on a dry run
+	run := &models.IngestionRun{SourceURL: cfg.SourceURL, FileName: src.Name, FileSHA256: src.SHA256}
+	if cfg.DryRun {
+		output := cfg.DiffOutput
+		if output == nil {
+			output = os.Stdout
+		}
+		if heading {
+			fmt.Fprintf(output, "# %s\n", src.Name)
+		}
+		opts.Diff = newDiffReport(output)
+	} else {
+		if err := store.StartIngestionRun(context.Background(), db, run); err != nil {
+			return result, fmt.Errorf("failed to start ingestion run: %w", err)
+		}
+		opts.RunID = &run.ID
+		result.Run = run
+	}
+
+	result.Stats, err = processCSV(ctx, r, db, geocoder, opts)
+	if src.streamed && err == nil {
+		// Hash anything the CSV reader did not consume, such as a trailing newline
+		if _, err = io.Copy(hash, file); err == nil {
+			run.FileSHA256 = hex.EncodeToString(hash.Sum(nil))
+		}
+	}
+	if opts.Diff != nil {
+		if err == nil {
+			err = opts.Diff.findDisappeared(context.Background(), db)
+		}
+		result.Disappeared = opts.Diff.Disappeared
+		log.Printf("Dry run of %s, nothing was written: %d new, %d modified, %d unchanged, %d disappeared, %d rejected",
+			src.Name, result.Stats.Inserted, result.Stats.Updated, result.Stats.Unchanged, opts.Diff.Disappeared, result.Stats.Failed)
+	} else {
+		log.Printf("Processed records from %s: %s", src.Name, result.Stats)
+	}
+
+	if err == nil {
+		if rate := result.Stats.rejectionRate(); rate > cfg.MaxRejected {
+			err = fmt.Errorf("rejected %.1f%% of records, more than the %.1f%% allowed", rate*100, cfg.MaxRejected*100)
+		}
+	}
+	if result.Run != nil {
+		finishIngestionRun(db, run, result.Stats, err)
+		linkAccidents(db)
+	}
+	return result, err
+}
+
+// previousImport returns the successful run that already imported the file with the SHA-256, so that it can be
+// skipped: re-importing it would only report unchanged records. Files are never skipped on dry runs or when
+// cfg.Force is set.
+func previousImport(db *store.DB, name, sha256 string, cfg Config) (*models.IngestionRun, error) {
+	if cfg.DryRun || cfg.Force {
+		return nil, nil
+	}
+	previous, err := store.SucceededIngestionRunBySHA256(context.Background(), db, sha256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check previous imports: %w", err)
+	}
+	if previous != nil {
+		log.Printf("%s was already imported by ingestion run %d on %s, nothing to import",
+			name, previous.ID, previous.StartedAt.Format(time.RFC3339))
+	}
+	return previous, nil
+}
+
+// openQuarantine creates the quarantine for records that are rejected or imported without coordinates, appending
+// them to cfg.QuarantinePath if set so that earlier runs' records are kept. The returned function closes the file.
+func openQuarantine(cfg Config) (*quarantine, func(), error) {
+	if cfg.QuarantinePath == "" {
+		return newQuarantine(nil), func() {}, nil
+	}
+	file, err := os.OpenFile(cfg.QuarantinePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	if info.Size() > 0 {
+		return appendQuarantine(file), func() { file.Close() }, nil
+	}
+	return newQuarantine(file), func() { file.Close() }, nil
+}
+
+// linkAccidents links the NTSB accidents to the FAA accidents imported so far. Failing to link them does not fail
+// the import, as they are linked again after the next one.
+func linkAccidents(db *store.DB) {
+	linked, err := store.LinkNTSBAccidents(context.Background(), db)
+	if err != nil {
+		log.Printf("Failed to link NTSB accidents: %v", err)
+		return
+	}
+	if linked > 0 {
+		log.Printf("Linked %d NTSB accidents to FAA accidents", linked)
+	}
+}
+
+// newGeocoder sets up the configured geocoder, rate limiting Google requests and caching their results between
+// runs. The cache is returned separately so it can be saved after the import.
+func newGeocoder(cfg Config) (Geocoder, *cachingGeocoder, error) {
+	switch cfg.Geocoder {
+	case "google":
+		var geocoder Geocoder = newGoogleGeocoder(cfg.GoogleMapsAPIKey)
+		if cfg.GeocodeRate > 0 {
+			geocoder = newRateLimitedGeocoder(geocoder, cfg.GeocodeRate)
+		}
+		if cfg.GeocodeCachePath == "" {
+			return geocoder, nil, nil
+		}
+		cache, err := newCachingGeocoder(geocoder, cfg.GeocodeCachePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("geocode cache setup failed: %w", err)
+		}
+		return cache, cache, nil
+	case "gazetteer":
+		if len(cfg.GazetteerPaths) == 0 {
+			return nil, nil, fmt.Errorf("the gazetteer geocoder requires gazetteer files")
+		}
+		geocoder, err := newGazetteerGeocoder(cfg.GazetteerPaths...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gazetteer setup failed: %w", err)
+		}
+		return geocoder, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown geocoder %q", cfg.Geocoder)
+	}
+}
+
+// verifyChecksum checks the file against the SHA-256 the downloader recorded in path + ".sha256", if any,
+// so a file that was modified or only partly copied since it was downloaded is not imported.
+func verifyChecksum(path, sum string) error {
+	data, err := os.ReadFile(path + ".sha256")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading checksum: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file %s.sha256", path)
+	}
+	if !strings.EqualFold(fields[0], sum) {
+		return fmt.Errorf("%s has SHA-256 %s, but %s.sha256 records %s", path, sum, path, fields[0])
+	}
+	return nil
+}
+
+// finishIngestionRun records the outcome of an import. A run fails when processing failed or more records were
+// rejected than allowed, and is interrupted when it was stopped before the end of the file.
+func finishIngestionRun(db *store.DB, run *models.IngestionRun, stats Stats, err error) {
+	run.RowsRead = stats.Inserted + stats.Updated + stats.Unchanged + stats.Failed
+	run.RowsInserted = stats.Inserted
+	run.RowsUpdated = stats.Updated
+	run.RowsUnchanged = stats.Unchanged
+	run.RowsFailed = stats.Failed
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		run.Status = store.IngestionInterrupted
+	case err != nil:
+		run.Status = store.IngestionFailed
+		run.Error = err.Error()
+	default:
+		run.Status = store.IngestionSucceeded
+	}
+
+	if err := store.FinishIngestionRun(context.Background(), db, run); err != nil {
+		log.Printf("Failed to record ingestion run: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Importer tests run whole imports of CSV files on disk, geocoded offline from the gazetteer samples.
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// TestRun tests that an import is recorded as a run, that importing the same file again is skipped unless
+// forced, and that a file that no longer matches its checksum is refused.
+func TestRun(t *testing.T) {
+	db := newTestDB(t)
+	path := filepath.Join(t.TempDir(), "faa.csv")
+	csv := strings.Join([]string{testCSVHeader, testCSVRow("01-JAN-23", "N1"), testCSVRow("02-JAN-23", "N2")}, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	cfg := Config{BatchSize: 10, Geocoder: "gazetteer", GazetteerPaths: []string{"testdata/geonames_sample.txt"}, MaxRejected: 0.05}
+	result, err := Run(context.Background(), db, FileSource(path), cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Run == nil || result.Run.Status != store.IngestionSucceeded || result.Run.RowsInserted != 2 || result.Run.FileSHA256 == "" {
+		t.Fatalf("Expected a succeeded run inserting 2 records, got %+v", result.Run)
+	}
+
+	result, err = Run(context.Background(), db, FileSource(path), cfg)
+	if err != nil || result.Skipped == nil || result.Skipped.ID != 1 || result.Run != nil {
+		t.Errorf("Expected the unchanged file to be skipped as imported by run 1, got %+v, %v", result, err)
+	}
+
+	cfg.Force = true
+	result, err = Run(context.Background(), db, FileSource(path), cfg)
+	if err != nil || result.Run == nil || result.Run.RowsUnchanged != 2 {
+		t.Errorf("Expected a forced import with 2 unchanged records, got %+v, %v", result, err)
+	}
+
+	if err := os.WriteFile(path+".sha256", []byte("0000  faa.csv\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write checksum: %v", err)
+	}
+	if _, err := Run(context.Background(), db, FileSource(path), cfg); err == nil || !strings.Contains(err.Error(), "faa.csv.sha256 records 0000") {
+		t.Errorf("Expected a checksum mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// Tables of the NTSB aviation accident database read by the importer. Each is a CSV file named after the table,
+// such as events.csv, as written by mdb-export from the NTSB's avall.mdb or pre2008.mdb.
+const (
+	ntsbEvents     = "events"     // One row per event, with its date and place
+	ntsbAircraft   = "aircraft"   // One row per aircraft involved in an event
+	ntsbInjury     = "injury"     // Injury counts per aircraft, person category and injury level
+	ntsbNarratives = "narratives" // Narratives and probable cause per aircraft
+)
+
+// ntsbTables lists the tables in the order they are read; events and aircraft are required.
+var ntsbTables = []string{ntsbEvents, ntsbAircraft, ntsbInjury, ntsbNarratives}
+
+// maxRemarkLength is the length of the remark_text column, to which NTSB narratives are truncated.
+const maxRemarkLength = 1024
+
+// RunNTSB imports the NTSB tables exported as CSV files into dir. Each aircraft of an event is imported as an
+// accident with source NTSB, identified by the event ID and aircraft key, and linked to the FAA accident of an
+// aircraft with the same registration on the same date. The tables are recorded as a single ingestion run and
+// skipped when a successful run imported the same files, unless cfg.Force is set. Dry runs are not supported.
+func RunNTSB(ctx context.Context, db *store.DB, dir string, cfg Config) (*Result, error) {
+	if cfg.DryRun {
+		return nil, errors.New("dry runs are not supported for NTSB data")
+	}
+
+	paths, err := findTables(dir, ".csv", ntsbTables, ntsbEvents, ntsbAircraft)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := hashTables(ntsbTables, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash tables: %w", err)
+	}
+
+	result := &Result{Name: filepath.Base(dir)}
+	previous, err := previousImport(db, result.Name, sum, cfg)
+	if err != nil || previous != nil {
+		result.Skipped = previous
+		return result, err
+	}
+
+	details, err := loadNTSBEventDetails(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	geocoder, cache, err := newGeocoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	q, closeQuarantine, err := openQuarantine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeQuarantine()
+	q.setFile(filepath.Base(paths[ntsbEvents]))
+
+	run := &models.IngestionRun{SourceURL: cfg.SourceURL, FileName: result.Name, FileSHA256: sum}
+	if err := store.StartIngestionRun(context.Background(), db, run); err != nil {
+		return nil, fmt.Errorf("failed to start ingestion run: %w", err)
+	}
+	result.Run = run
+
+	opts := importOptions{
+		BatchSize:        cfg.BatchSize,
+		BufferSize:       cfg.BufferSize,
+		Workers:          cfg.Workers,
+		ProgressInterval: cfg.ProgressInterval,
+		Quarantine:       q,
+		RunID:            &run.ID,
+	}
+	result.Stats, err = processNTSB(ctx, paths[ntsbEvents], details, db, geocoder, opts)
+	log.Printf("Processed NTSB records: %s", result.Stats)
+	if summary := q.Summary(); summary != "" {
+		log.Printf("Quarantined records: %s", summary)
+	}
+	if err := q.Flush(); err != nil {
+		log.Printf("Failed to write quarantine file: %v", err)
+	}
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			log.Printf("Failed to save geocode cache: %v", err)
+		}
+	}
+
+	if err == nil {
+		if rate := result.Stats.rejectionRate(); rate > cfg.MaxRejected {
+			err = fmt.Errorf("rejected %.1f%% of records, more than the %.1f%% allowed", rate*100, cfg.MaxRejected*100)
+		}
+	}
+	finishIngestionRun(db, run, result.Stats, err)
+	linkAccidents(db)
+	return result, err
+}
+
+// ntsbAircraftRow is an aircraft of an event with its injuries and narrative.
+type ntsbAircraftRow struct {
+	key      string // Aircraft_Key, numbering the aircraft within the event
+	aircraft *models.Aircraft
+	missing  string
+	damage   string
+	farPart  string
+	activity string
+	injuries map[string]int // Injury counts by person type and severity, "passengers/minor"
+	remark   string
+}
+
+// ntsbEventDetails holds the aircraft, injury and narrative tables, by event ID, for joining with the events.
+type ntsbEventDetails map[string][]*ntsbAircraftRow
+
+// loadNTSBEventDetails reads the aircraft of every event with their injuries and narratives.
+func loadNTSBEventDetails(paths map[string]string) (ntsbEventDetails, error) {
+	details := make(ntsbEventDetails)
+	byKey := make(map[string]*ntsbAircraftRow)
+
+	err := readCSVTable(paths[ntsbAircraft], ntsbAircraft, []string{"ev_id", "Aircraft_Key", "regis_no"}, func(t *csvTable, _ int, fields []string) error {
+		evID, key := t.get(fields, "ev_id"), t.get(fields, "Aircraft_Key")
+		row := &ntsbAircraftRow{
+			key: key,
+			aircraft: &models.Aircraft{
+				RegistrationNumber: store.NormalizeRegistration(t.get(fields, "regis_no")),
+				AircraftMakeName:   strings.ToUpper(t.get(fields, "acft_make")),
+				AircraftModelName:  strings.ToUpper(t.get(fields, "acft_model")),
+				AircraftOperator:   strings.ToUpper(t.get(fields, "oper_name")),
+			},
+			missing:  ntsbCode(ntsbYesNo, t.get(fields, "acft_missing")),
+			damage:   ntsbCode(ntsbDamage, t.get(fields, "damage")),
+			farPart:  normalizeFARPart(t.get(fields, "far_part")),
+			activity: ntsbCode(ntsbFlightActivities, t.get(fields, "type_fly")),
+			injuries: make(map[string]int),
+		}
+		details[evID] = append(details[evID], row)
+		byKey[evID+"/"+key] = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if path, ok := paths[ntsbInjury]; ok {
+		required := []string{"ev_id", "Aircraft_Key", "inj_person_category", "injury_level", "inj_person_count"}
+		err := readCSVTable(path, ntsbInjury, required, func(t *csvTable, _ int, fields []string) error {
+			row, ok := byKey[t.get(fields, "ev_id")+"/"+t.get(fields, "Aircraft_Key")]
+			if !ok {
+				return nil
+			}
+			personType, ok := ntsbPersonTypes[strings.ToUpper(t.get(fields, "inj_person_category"))]
+			if !ok {
+				return nil
+			}
+			severity, ok := ntsbInjuryLevels[strings.ToUpper(t.get(fields, "injury_level"))]
+			if !ok {
+				return nil
+			}
+			count, err := strconv.Atoi(t.get(fields, "inj_person_count"))
+			if err != nil || count == 0 {
+				return nil
+			}
+			row.injuries[personType+"/"+severity] += count
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if path, ok := paths[ntsbNarratives]; ok {
+		err := readCSVTable(path, ntsbNarratives, []string{"ev_id", "Aircraft_Key"}, func(t *csvTable, _ int, fields []string) error {
+			row, ok := byKey[t.get(fields, "ev_id")+"/"+t.get(fields, "Aircraft_Key")]
+			if !ok {
+				return nil
+			}
+			// Prefer the probable cause, then the factual and the preliminary narratives
+			for _, column := range []string{"narr_cause", "narr_accf", "narr_accp"} {
+				if text := t.get(fields, column); text != "" {
+					row.remark = truncateRemark(strings.Join(strings.Fields(text), " "))
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return details, nil
+}
+
+// processNTSB reads the events and writes a record for each of their aircraft, through the geocoding and writing
+// stages of the FAA pipeline. Cancelling ctx stops reading the events and lets the records already read be written.
+func processNTSB(ctx context.Context, eventsPath string, details ntsbEventDetails, db *store.DB, geocoder Geocoder, opts importOptions) (Stats, error) {
+	read := func(ctx context.Context, stop <-chan struct{}, out chan<- *parsedRecord, q *quarantine, progress *pipelineProgress) error {
+		return readNTSBEvents(ctx, stop, eventsPath, details, out, q, progress)
+	}
+	return runPipeline(ctx, read, db, geocoder, opts)
+}
+
+// errStopped ends reading the events table when the import is interrupted.
+var errStopped = errors.New("stopped")
+
+// readNTSBEvents parses each event into a record per aircraft, quarantining the events that cannot be parsed,
+// until the end of the table or until stop is closed.
+func readNTSBEvents(ctx context.Context, stop <-chan struct{}, path string, details ntsbEventDetails, out chan<- *parsedRecord, q *quarantine, progress *pipelineProgress) error {
+	required := []string{"ev_id", "ev_type", "ev_date", "ev_city", "ev_state", "ev_country"}
+	err := readCSVTable(path, ntsbEvents, required, func(t *csvTable, line int, fields []string) error {
+		if isClosed(stop) {
+			return errStopped
+		}
+		progress.read.Add(1)
+
+		records, err := parseNTSBEvent(t, fields, details[t.get(fields, "ev_id")])
+		if err != nil {
+			log.Printf("Failed to process event on line %d: %v", line, err)
+			progress.parseFailed.Add(1)
+			q.add(line, fields, reasonInvalid, err)
+			return nil
+		}
+		for _, record := range records {
+			record.Line = line
+			if err := send(ctx, out, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == errStopped {
+		return nil
+	}
+	return err
+}
+
+// parseNTSBEvent converts an event and its aircraft into the records to write.
+func parseNTSBEvent(t *csvTable, fields []string, aircraft []*ntsbAircraftRow) ([]*parsedRecord, error) {
+	if len(aircraft) == 0 {
+		return nil, errors.New("the event has no aircraft")
+	}
+
+	eventDate, err := parseNTSBDate(t.get(fields, "ev_date"))
+	if err != nil {
+		return nil, &fieldError{Field: "ev_date", Reason: reasonBadDate, Err: err}
+	}
+	entryDate := eventDate
+	if value := t.get(fields, "lchg_date"); value != "" {
+		if entryDate, err = parseNTSBDate(value); err != nil {
+			return nil, &fieldError{Field: "lchg_date", Reason: reasonBadDate, Err: err}
+		}
+	}
+	eventTime, err := parseNTSBTime(t.get(fields, "ev_time"))
+	if err != nil {
+		return nil, &fieldError{Field: "ev_time", Reason: reasonBadTime, Err: err}
+	}
+
+	country := t.get(fields, "ev_country")
+	state := t.get(fields, "ev_state")
+	if country == "USA" {
+		country = "United States"
+		if name, ok := usStateNames[strings.ToUpper(state)]; ok {
+			state = name
+		}
+	}
+	eventType := ntsbCode(ntsbEventTypes, t.get(fields, "ev_type"))
+	fatalEvent := strings.EqualFold(t.get(fields, "ev_highest_injury"), "FATL")
+
+	records := make([]*parsedRecord, 0, len(aircraft))
+	for i, row := range aircraft {
+		injuries := make(map[string]int, len(row.injuries))
+		for key, count := range row.injuries {
+			injuries[key] = count
+		}
+		// Ground injuries are counted per event; attribute them to the first aircraft
+		if i == 0 {
+			for column, severity := range map[string]string{"inj_f_grnd": "fatal", "inj_s_grnd": "serious", "inj_m_grnd": "minor"} {
+				if count, err := strconv.Atoi(t.get(fields, column)); err == nil && count > 0 {
+					injuries["ground/"+severity] += count
+				}
+			}
+		}
+
+		fatalFlag := ""
+		if fatalEvent || injuries["flight_crew/fatal"]+injuries["cabin_crew/fatal"]+injuries["passengers/fatal"]+injuries["ground/fatal"] > 0 {
+			fatalFlag = "Yes"
+		}
+
+		aircraftCopy := *row.aircraft
+		records = append(records, &parsedRecord{
+			Fields:   fields,
+			Aircraft: &aircraftCopy,
+			Accident: &models.Accident{
+				EntryDate:                 entryDate,
+				EventLocalDate:            eventDate,
+				EventLocalTime:            eventTime,
+				RemarkText:                row.remark,
+				EventTypeDescription:      eventType,
+				AircraftMissingFlag:       row.missing,
+				AircraftDamageDescription: row.damage,
+				FlightActivity:            row.activity,
+				FARPart:                   row.farPart,
+				FatalFlag:                 fatalFlag,
+				Source:                    store.SourceNTSB,
+				SourceID:                  t.get(fields, "ev_id") + "-" + row.key,
+			},
+			Location: &models.Location{
+				CityName:    strings.ToUpper(t.get(fields, "ev_city")),
+				StateName:   state,
+				CountryName: country,
+			},
+			Injuries: ntsbInjuries(injuries),
+		})
+	}
+	return records, nil
+}
+
+// ntsbInjuries converts injury counts keyed by "person type/severity" into injuries, in a stable order.
+func ntsbInjuries(counts map[string]int) []*models.Injury {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	injuries := make([]*models.Injury, 0, len(keys))
+	for _, key := range keys {
+		personType, severity, _ := strings.Cut(key, "/")
+		injuries = append(injuries, &models.Injury{PersonType: personType, InjurySeverity: severity, Count: counts[key]})
+	}
+	return injuries
+}
+
+// ntsbTwoDigitYearLayout is mdb-export's default date format, with two-digit years.
+const ntsbTwoDigitYearLayout = "01/02/06 15:04:05"
+
+// ntsbDateLayouts are the date formats found in NTSB exports: mdb-export's default with two-digit years, with
+// four-digit years, and ISO dates.
+var ntsbDateLayouts = []string{ntsbTwoDigitYearLayout, "01/02/2006 15:04:05", "01/02/2006", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseNTSBDate parses a date in any of the NTSB export formats. Two-digit years that would be in the future are
+// taken to be in the previous century, as the NTSB data starts in 1962; future dates with four-digit years are
+// rejected.
+func parseNTSBDate(value string) (time.Time, error) {
+	for _, layout := range ntsbDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if t.After(time.Now()) {
+			if layout != ntsbTwoDigitYearLayout {
+				return time.Time{}, fmt.Errorf("date '%s' is in the future", value)
+			}
+			t = t.AddDate(-100, 0, 0)
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("error parsing date '%s'", value)
+}
+
+// parseNTSBTime converts an NTSB local time, the hours and minutes as a number such as 1530, to the format of the
+// FAA data. Events without a time are imported with an empty time.
+func parseNTSBTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n/100 > 23 || n%100 > 59 {
+		return "", fmt.Errorf("error parsing time '%s'", value)
+	}
+	return fmt.Sprintf("%02d:%02d:00", n/100, n%100), nil
+}
+
+// truncateRemark shortens a narrative to fit the remark_text column.
+func truncateRemark(text string) string {
+	if utf8.RuneCountInString(text) <= maxRemarkLength {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxRemarkLength-3]) + "..."
+}
+
+// ntsbCode returns the description of an NTSB code, or the code itself when it is not in the table.
+func ntsbCode(descriptions map[string]string, code string) string {
+	if description, ok := descriptions[strings.ToUpper(code)]; ok {
+		return description
+	}
+	return code
+}
+
+// Descriptions of the NTSB codes, worded as in the FAA data.
+var (
+	ntsbEventTypes = map[string]string{"ACC": "Accident", "INC": "Incident"}
+	ntsbYesNo      = map[string]string{"Y": "Yes", "N": "No"}
+	ntsbDamage     = map[string]string{"DEST": "Destroyed", "SUBS": "Substantial", "MINR": "Minor", "NONE": "None", "UNK": "Unknown"}
+
+	ntsbFlightActivities = map[string]string{
+		"PERS": "Personal",
+		"BUS":  "Business",
+		"INST": "Instruction",
+		"AAPL": "Aerial Application",
+		"POSI": "Positioning",
+		"OWRK": "Other Work Use",
+		"FERY": "Ferry",
+		"PUBU": "Public Use",
+		"SKYD": "Skydiving",
+		"BANT": "Banner Tow",
+		"FLTS": "Flight Test",
+		"EXEC": "Executive/Corporate",
+		"UNK":  "Unknown",
+	}
+
+	// ntsbPersonTypes maps inj_person_category to the person types of the FAA data; totals are skipped.
+	ntsbPersonTypes = map[string]string{
+		"PLT":  "flight_crew",
+		"CPLT": "flight_crew",
+		"DSTU": "flight_crew",
+		"FLTI": "flight_crew",
+		"CHKP": "flight_crew",
+		"FENG": "flight_crew",
+		"OCRW": "flight_crew",
+		"CABN": "cabin_crew",
+		"PASS": "passengers",
+	}
+
+	// ntsbInjuryLevels maps injury_level to the severities of the FAA data; totals are skipped.
+	ntsbInjuryLevels = map[string]string{"FATL": "fatal", "SERS": "serious", "MINR": "minor", "NONE": "none", "UNKN": "unknown"}
+)
+
+// usStateNames maps the state codes of the NTSB data to the state names of the FAA data.
+var usStateNames = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California", "CO": "Colorado",
+	"CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia", "FL": "Florida", "GA": "Georgia",
+	"HI": "Hawaii", "ID": "Idaho", "IL": "Illinois", "IN": "Indiana", "IA": "Iowa", "KS": "Kansas",
+	"KY": "Kentucky", "LA": "Louisiana", "ME": "Maine", "MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan",
+	"MN": "Minnesota", "MS": "Mississippi", "MO": "Missouri", "MT": "Montana", "NE": "Nebraska", "NV": "Nevada",
+	"NH": "New Hampshire", "NJ": "New Jersey", "NM": "New Mexico", "NY": "New York", "NC": "North Carolina",
+	"ND": "North Dakota", "OH": "Ohio", "OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island",
+	"SC": "South Carolina", "SD": "South Dakota", "TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont",
+	"VA": "Virginia", "WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+	"PR": "Puerto Rico", "GU": "Guam", "VI": "Virgin Islands", "AS": "American Samoa", "MP": "Northern Mariana Islands",
+}
@@ -0,0 +1,138 @@
+// NTSB tests import a small export of the NTSB tables in testdata/ntsb next to FAA records, geocoded offline from
+// the gazetteer sample.
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunNTSB tests that each aircraft of an NTSB event is imported as an accident linked to the FAA accident with
+// the same registration and date, including FAA registrations written without the N prefix, that FAA aircraft are
+// left as they are, and that the import is recorded as a run.
+func TestRunNTSB(t *testing.T) {
+	db := newTestDB(t)
+	cfg := Config{BatchSize: 10, Geocoder: "gazetteer", GazetteerPaths: []string{"testdata/geonames_sample.txt"}, MaxRejected: 0.5}
+
+	path := filepath.Join(t.TempDir(), "faa.csv")
+	csv := strings.Join([]string{testCSVHeader, testCSVRow("01-JAN-23", "N1"), testCSVRow("01-FEB-23", "2")}, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Run(context.Background(), db, FileSource(path), cfg); err != nil {
+		t.Fatalf("Expected no error importing FAA file, got %v", err)
+	}
+
+	result, err := RunNTSB(context.Background(), db, "testdata/ntsb", cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The third event has an invalid time
+	if result.Stats.Inserted != 3 || result.Stats.Failed != 1 || result.Run == nil || result.Run.FileName != "ntsb" {
+		t.Fatalf("Expected a run inserting 3 records and rejecting 1, got %+v, %+v", result.Stats, result.Run)
+	}
+
+	rows, err := db.Query(`
+		SELECT n.source_id, COALESCE(fa.registration_number, '')
+		FROM Accidents n
+		LEFT JOIN Accidents f ON f.id = n.linked_accident_id
+		LEFT JOIN Aircrafts fa ON fa.id = f.aircraft_id
+		WHERE n.source = 'NTSB'
+		ORDER BY n.source_id`)
+	if err != nil {
+		t.Fatalf("Failed to query accidents: %v", err)
+	}
+	var links []string
+	for rows.Next() {
+		var sourceID, linked string
+		if err := rows.Scan(&sourceID, &linked); err != nil {
+			t.Fatalf("Failed to scan accident: %v", err)
+		}
+		links = append(links, sourceID+":"+linked)
+	}
+	rows.Close()
+	if got, expected := strings.Join(links, ","), "20230101X00001-1:N1,20230201X00002-1:2,20230201X00002-2:"; got != expected {
+		t.Errorf("Expected links %s, got %s", expected, got)
+	}
+
+	var remark, eventTime, damage, farPart, fatalFlag, state, makeName string
+	var entryDate time.Time
+	err = db.QueryRow(`
+		SELECT a.remark_text, a.event_local_time, a.aircraft_damage_description, a.far_part, a.fatal_flag, a.entry_date,
+			l.state_name, ac.aircraft_make_name
+		FROM Accidents a
+		JOIN Locations l ON l.id = a.location_id
+		JOIN Aircrafts ac ON ac.id = a.aircraft_id
+		WHERE a.source_id = '20230101X00001-1'`).Scan(&remark, &eventTime, &damage, &farPart, &fatalFlag, &entryDate, &state, &makeName)
+	if err != nil {
+		t.Fatalf("Failed to query accident: %v", err)
+	}
+	if remark != "The loss of engine power due to fuel exhaustion." || eventTime != "10:00:00" || damage != "Destroyed" ||
+		farPart != "091" || fatalFlag != "Yes" || entryDate.Format(dateLayout) != "2023-03-15" || state != "Texas" {
+		t.Errorf("Expected the event's fields in the FAA format, got %q %q %q %q %q %s %q", remark, eventTime, damage, farPart, fatalFlag, entryDate.Format(dateLayout), state)
+	}
+	if makeName != "" {
+		t.Errorf("Expected the FAA aircraft N1 to be left as it is, got make %q", makeName)
+	}
+	if got := countRows(t, db, "Aircrafts"); got != 3 {
+		t.Errorf("Expected N3 to be the only aircraft added, got %d aircraft", got)
+	}
+
+	injuries, err := db.Query(`
+		SELECT i.person_type, i.injury_severity, i.count
+		FROM Injuries i JOIN Accidents a ON a.id = i.accident_id
+		WHERE a.source_id = '20230101X00001-1'
+		ORDER BY i.person_type`)
+	if err != nil {
+		t.Fatalf("Failed to query injuries: %v", err)
+	}
+	var counts []string
+	for injuries.Next() {
+		var personType, severity, count string
+		if err := injuries.Scan(&personType, &severity, &count); err != nil {
+			t.Fatalf("Failed to scan injury: %v", err)
+		}
+		counts = append(counts, personType+"/"+severity+"="+count)
+	}
+	injuries.Close()
+	if got, expected := strings.Join(counts, ","), "flight_crew/fatal=1,passengers/none=2"; got != expected {
+		t.Errorf("Expected injuries %s without totals, got %s", expected, got)
+	}
+
+	runID := result.Run.ID
+	result, err = RunNTSB(context.Background(), db, "testdata/ntsb", cfg)
+	if err != nil || result.Skipped == nil || result.Skipped.ID != runID || result.Run != nil {
+		t.Errorf("Expected the unchanged tables to be skipped, got %+v, %v", result, err)
+	}
+}
+
+// TestParseNTSBDate tests the date formats of NTSB exports, including two-digit years before 1969, and that future
+// dates with four-digit years are rejected.
+func TestParseNTSBDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"01/15/08 00:00:00", "2008-01-15"},
+		{"07/04/62 00:00:00", "1962-07-04"},
+		{"01/15/2008 00:00:00", "2008-01-15"},
+		{"2008-01-15", "2008-01-15"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNTSBDate(tt.value)
+		if err != nil || got.Format(dateLayout) != tt.expected {
+			t.Errorf("Expected %q to parse as %s, got %s, %v", tt.value, tt.expected, got.Format(dateLayout), err)
+		}
+	}
+	if _, err := parseNTSBDate("15.01.2008"); err == nil {
+		t.Errorf("Expected an unknown format to be rejected")
+	}
+	if got, err := parseNTSBDate("01/15/2999"); err == nil {
+		t.Errorf("Expected a future four-digit year to be rejected, got %s", got.Format(dateLayout))
+	}
+}
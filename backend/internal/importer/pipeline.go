@@ -0,0 +1,354 @@
+package importer
+
+import (
+	"container/heap"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/store"
+	"golang.org/x/sync/errgroup"
+)
+
+// importOptions configures an import run.
+type importOptions struct {
+	BatchSize        int           // Records written per transaction
+	BufferSize       int           // Capacity of the channels between pipeline stages
+	ReorderWindow    int           // Records buffered to write them in descending ENTRY_DATE order, 0 to keep file order
+	Workers          int           // Records geocoded concurrently
+	Quarantine       *quarantine   // Receives rejected records and records imported without coordinates, nil to only log them
+	Diff             *diffReport   // Reports what would change instead of writing, for dry runs
+	RunID            *int          // Ingestion run the written accidents are linked to, nil when the import is not recorded
+	ProgressInterval time.Duration // How often progress is logged, 0 to disable
+}
+
+// csvRow is a raw CSV record and the line it starts on.
+type csvRow struct {
+	Line   int
+	Fields []string
+}
+
+// pipelineProgress counts records as they move through the pipeline. Each counter is updated by a single stage,
+// possibly from several workers, and read by the progress reporter.
+type pipelineProgress struct {
+	read        atomic.Int64
+	parseFailed atomic.Int64
+	geocoded    atomic.Int64
+	late        atomic.Int64 // Records written out of ENTRY_DATE order because they fell outside the reorder window
+}
+
+// processCSV streams the CSV through a pipeline of reader → parser → reorder → geocoder → writer stages
+// connected by bounded channels, so memory use is independent of the file size and records are written
+// as soon as they are ready.
+//
+// Cancelling ctx stops reading the file; the records already read are still geocoded and written, so that
+// an interrupted import ends on a committed batch, and ctx.Err() is returned.
+//
+// The header is checked before anything is written: when the columns the importer reads cannot all be found,
+// processCSV returns a *schemaDriftError.
+func processCSV(ctx context.Context, r io.Reader, db *store.DB, geocoder Geocoder, opts importOptions) (Stats, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Records with the wrong number of fields are rejected by parseRecord
+	header, err := reader.Read()
+	if err != nil {
+		return Stats{}, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns, err := newColumnMap(header)
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(columns.ignored) > 0 {
+		log.Printf("Ignoring CSV columns the importer does not read: %s", strings.Join(columns.ignored, ", "))
+	}
+
+	read := func(ctx context.Context, stop <-chan struct{}, out chan<- *parsedRecord, q *quarantine, progress *pipelineProgress) error {
+		g, ctx := errgroup.WithContext(ctx)
+		rows := make(chan csvRow, opts.BufferSize)
+		g.Go(func() error {
+			defer close(rows)
+			return readRows(ctx, stop, reader, rows, progress)
+		})
+		g.Go(func() error {
+			return parseRows(ctx, columns, rows, out, q, progress)
+		})
+		return g.Wait()
+	}
+	return runPipeline(ctx, read, db, geocoder, opts)
+}
+
+// readStage reads records from a source and sends them on out until the end of the source or until stop is
+// closed, quarantining and counting the ones that cannot be parsed.
+type readStage func(ctx context.Context, stop <-chan struct{}, out chan<- *parsedRecord, q *quarantine, progress *pipelineProgress) error
+
+// runPipeline connects the read stage to the reorder → geocoder → writer stages with bounded channels and runs
+// them until every record read is written. Cancelling ctx only closes the read stage's stop channel, so the
+// records already read are still written, and ctx.Err() is returned.
+func runPipeline(ctx context.Context, read readStage, db *store.DB, geocoder Geocoder, opts importOptions) (Stats, error) {
+	q := opts.Quarantine
+	if q == nil {
+		q = newQuarantine(nil)
+	}
+
+	var progress pipelineProgress
+	writer := newBatchWriter(db, opts.BatchSize, q)
+	writer.runID = opts.RunID
+	if opts.Diff != nil {
+		writer.write = opts.Diff.diffBatch
+	}
+
+	stop := ctx.Done()
+	g, ctx := errgroup.WithContext(context.WithoutCancel(ctx))
+	parsed := make(chan *parsedRecord, opts.BufferSize)
+	ordered := make(chan *parsedRecord, opts.BufferSize)
+	geocoded := make(chan *parsedRecord, opts.BufferSize)
+
+	g.Go(func() error {
+		defer close(parsed)
+		return read(ctx, stop, parsed, q, &progress)
+	})
+	g.Go(func() error {
+		defer close(ordered)
+		return reorderRecords(ctx, parsed, ordered, opts.ReorderWindow, &progress)
+	})
+	g.Go(func() error {
+		defer close(geocoded)
+		return geocodeRecords(ctx, ordered, geocoded, geocoder, opts.Workers, q, &progress)
+	})
+	g.Go(func() error {
+		return writeRecords(ctx, geocoded, writer, opts.ProgressInterval, &progress)
+	})
+
+	err := g.Wait()
+	if err == nil && isClosed(stop) {
+		err = context.Canceled
+	}
+
+	stats := writer.stats
+	stats.Failed += int(progress.parseFailed.Load())
+	if late := progress.late.Load(); late > 0 {
+		log.Printf("%d records were written out of ENTRY_DATE order; increase -reorder-window to sort them", late)
+	}
+	return stats, err
+}
+
+// send delivers v on ch unless the context is cancelled first.
+func send[T any](ctx context.Context, ch chan<- T, v T) error {
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isClosed reports whether ch is closed, without blocking.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// readRows reads the CSV records until the end of the file or until stop is closed.
+func readRows(ctx context.Context, stop <-chan struct{}, reader *csv.Reader, out chan<- csvRow, progress *pipelineProgress) error {
+	for !isClosed(stop) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		progress.read.Add(1)
+
+		if err := send(ctx, out, csvRow{Line: line, Fields: record}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRows parses CSV records, quarantining and counting the ones that cannot be parsed.
+func parseRows(ctx context.Context, columns *columnMap, in <-chan csvRow, out chan<- *parsedRecord, q *quarantine, progress *pipelineProgress) error {
+	for row := range in {
+		record, err := parseRecord(columns, row.Fields)
+		if err != nil {
+			log.Printf("Failed to process record on line %d: %v", row.Line, err)
+			progress.parseFailed.Add(1)
+			q.add(row.Line, row.Fields, reasonInvalid, err)
+			continue
+		}
+		record.Line = row.Line
+
+		if err := send(ctx, out, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reorderRecords emits records in descending ENTRY_DATE order, as the importer has always inserted them,
+// without sorting the whole file: up to window records are held in a heap and the newest is released whenever
+// the heap is full. The result is fully sorted when no record is more than window positions out of place,
+// as in exports that are already roughly in date order; records that arrive later than that are passed on and counted.
+func reorderRecords(ctx context.Context, in <-chan *parsedRecord, out chan<- *parsedRecord, window int, progress *pipelineProgress) error {
+	if window <= 0 {
+		for record := range in {
+			if err := send(ctx, out, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var pending recordHeap
+	var last *parsedRecord
+	release := func() error {
+		record := heap.Pop(&pending).(*parsedRecord)
+		if last != nil && record.Accident.EntryDate.After(last.Accident.EntryDate) {
+			progress.late.Add(1)
+		}
+		last = record
+		return send(ctx, out, record)
+	}
+
+	for record := range in {
+		heap.Push(&pending, record)
+		if pending.Len() > window {
+			if err := release(); err != nil {
+				return err
+			}
+		}
+	}
+	for pending.Len() > 0 {
+		if err := release(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHeap is a max-heap of records by ENTRY_DATE, breaking ties by file order.
+type recordHeap []*parsedRecord
+
+// Len, Less, Swap, Push and Pop implement heap.Interface.
+func (h recordHeap) Len() int { return len(h) }
+
+func (h recordHeap) Less(i, j int) bool {
+	a, b := h[i].Accident.EntryDate, h[j].Accident.EntryDate
+	if !a.Equal(b) {
+		return a.After(b)
+	}
+	return h[i].Line < h[j].Line
+}
+
+func (h recordHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *recordHeap) Push(x interface{}) { *h = append(*h, x.(*parsedRecord)) }
+
+func (h *recordHeap) Pop() interface{} {
+	old := *h
+	record := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return record
+}
+
+// geocodeJob is a record handed to a geocoding worker; done is closed once its location is geocoded.
+type geocodeJob struct {
+	record *parsedRecord
+	done   chan struct{}
+}
+
+// geocodeRecords sets the coordinates of each record's location using a pool of workers, and emits the
+// records in the order they arrived so that accident IDs are assigned deterministically. Jobs are queued
+// in arrival order as well as handed to the workers; the queue holds one job per worker, so a slow lookup
+// holds back at most that many finished records. Records whose place cannot be geocoded are quarantined
+// but still written.
+func geocodeRecords(ctx context.Context, in <-chan *parsedRecord, out chan<- *parsedRecord, geocoder Geocoder, workers int, q *quarantine, progress *pipelineProgress) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	jobs := make(chan geocodeJob)
+	queue := make(chan geocodeJob, workers)
+
+	g.Go(func() error {
+		defer close(jobs)
+		defer close(queue)
+		for record := range in {
+			job := geocodeJob{record: record, done: make(chan struct{})}
+			if err := send(ctx, queue, job); err != nil {
+				return err
+			}
+			if err := send(ctx, jobs, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for job := range jobs {
+				if err := geocodeLocation(ctx, geocoder, job.record.Location); err != nil {
+					err = &fieldError{Field: faaColumnHeaders[colCityName], Reason: reasonGeocodeFailed, Err: err}
+					q.add(job.record.Line, job.record.Fields, reasonGeocodeFailed, err)
+				}
+				progress.geocoded.Add(1)
+				close(job.done)
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		for job := range queue {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if err := send(ctx, out, job.record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
+
+// writeRecords writes records in batches, logging progress every interval.
+func writeRecords(ctx context.Context, in <-chan *parsedRecord, writer *batchWriter, interval time.Duration, progress *pipelineProgress) error {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	start := time.Now()
+
+	for {
+		select {
+		case record, ok := <-in:
+			if !ok {
+				writer.flush(ctx)
+				return nil
+			}
+			writer.add(ctx, record)
+		case <-tick:
+			stats := writer.stats
+			stats.Failed += int(progress.parseFailed.Load())
+			log.Printf("Progress after %s: %d read, %d geocoded, %d pending, %s",
+				time.Since(start).Round(time.Second), progress.read.Load(), progress.geocoded.Load(), len(writer.pending), stats)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
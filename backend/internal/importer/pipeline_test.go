@@ -0,0 +1,263 @@
+// Pipeline tests stream small FAA-style CSV files through the importer into a temporary SQLite database.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// testCSVHeader is the header line of the test CSV files; the importer skips it.
+const testCSVHeader = "UPDATED,ENTRY_DATE,EVENT_LCL_DATE,EVENT_LCL_TIME,LOC_CITY_NAME,LOC_STATE_NAME,LOC_CNTRY_NAME,RMK_TEXT,EVENT_TYPE_DESC,FSDO_DESC,REGIST_NBR,FLT_NBR,ACFT_OPRTR,ACFT_MAKE_NAME,ACFT_MODEL_NAME,ACFT_MISSING_FLAG,ACFT_DMG_DESC,FLT_ACTIVITY,FLT_PHASE,FAR_PART,MAX_INJ_LVL,FATAL_FLAG,FLT_CRW_INJ_NONE,FLT_CRW_INJ_MINOR,FLT_CRW_INJ_SERIOUS,FLT_CRW_INJ_FATAL,FLT_CRW_INJ_UNK,CBN_CRW_INJ_NONE,CBN_CRW_INJ_MINOR,CBN_CRW_INJ_SERIOUS,CBN_CRW_INJ_FATAL,CBN_CRW_INJ_UNK,PAX_INJ_NONE,PAX_INJ_MINOR,PAX_INJ_SERIOUS,PAX_INJ_FATAL,PAX_INJ_UNK,GRND_INJ_NONE,GRND_INJ_MINOR,GRND_INJ_SERIOUS,GRND_INJ_FATAL,GRND_INJ_UNK"
+
+// testCSVRow returns a CSV line for an accident with one uninjured crew member.
+func testCSVRow(entryDate, registration string) string {
+	fields := make([]string, 42)
+	fields[0] = "No"
+	fields[1] = entryDate
+	fields[2] = entryDate
+	fields[3] = "10:00:00Z"
+	fields[4], fields[5], fields[6] = "AUSTIN", "Texas", "United States"
+	fields[7] = "AIRCRAFT LANDED HARD."
+	fields[8], fields[9] = "Accident", "FSDO"
+	fields[10] = registration
+	fields[22] = "1"
+	return strings.Join(fields, ",")
+}
+
+// TestProcessCSV tests streaming a file into the database, written newest entry date first,
+// with unparseable records counted as failed.
+func TestProcessCSV(t *testing.T) {
+	db := newTestDB(t)
+
+	csv := strings.Join([]string{
+		testCSVHeader,
+		testCSVRow("01-JAN-23", "N1"),
+		testCSVRow("03-JAN-23", "N3"),
+		testCSVRow("not a date", "N9"),
+		testCSVRow("02-JAN-23", "N2"),
+	}, "\n") + "\n"
+
+	geocoder := &fakeGeocoder{places: map[string]coordinates{"AUSTIN, TEXAS, UNITED STATES": {30.27, -97.74}}}
+	opts := importOptions{BatchSize: 2, BufferSize: 1, ReorderWindow: 10}
+
+	stats, err := processCSV(context.Background(), strings.NewReader(csv), db, geocoder, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := Stats{Inserted: 3, Failed: 1}
+	if stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, stats)
+	}
+
+	if order := accidentRegistrations(t, db); strings.Join(order, ",") != "N3,N2,N1" {
+		t.Errorf("Expected accidents inserted newest first as N3,N2,N1, got %v", order)
+	}
+
+	if got := countRows(t, db, "Injuries"); got != 3 {
+		t.Errorf("Expected 3 injuries, got %d", got)
+	}
+}
+
+// accidentRegistrations returns the registration numbers of the accidents in ID order.
+func accidentRegistrations(t *testing.T, db *store.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT ac.registration_number FROM Accidents a JOIN Aircrafts ac ON ac.id = a.aircraft_id ORDER BY a.id")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer rows.Close()
+	var order []string
+	for rows.Next() {
+		var registration string
+		if err := rows.Scan(&registration); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		order = append(order, registration)
+	}
+	return order
+}
+
+// delayGeocoder answers after a delay that varies by place, so concurrent lookups finish out of order.
+type delayGeocoder struct{}
+
+// Geocode sleeps for up to 4ms depending on the place and returns fixed coordinates.
+func (delayGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	time.Sleep(time.Duration(len(place)%5) * time.Millisecond)
+	return coordinates{Latitude: 30.27, Longitude: -97.74}, nil
+}
+
+// TestProcessCSV_Workers tests that records geocoded concurrently are still written in file order.
+func TestProcessCSV_Workers(t *testing.T) {
+	db := newTestDB(t)
+
+	lines := []string{testCSVHeader}
+	var expected []string
+	for i := 0; i < 40; i++ {
+		registration := fmt.Sprintf("N%d", i)
+		row := strings.Split(testCSVRow("01-JAN-23", registration), ",")
+		row[4] = strings.Repeat("X", i) // Vary the place, and so the geocoding delay
+		lines = append(lines, strings.Join(row, ","))
+		expected = append(expected, registration)
+	}
+	csv := strings.Join(lines, "\n") + "\n"
+
+	opts := importOptions{BatchSize: 7, BufferSize: 2, Workers: 8}
+	stats, err := processCSV(context.Background(), strings.NewReader(csv), db, delayGeocoder{}, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.Inserted != 40 {
+		t.Errorf("Expected 40 inserted, got %+v", stats)
+	}
+	if got := accidentRegistrations(t, db); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected accidents in file order, got %v", got)
+	}
+}
+
+// cancelGeocoder cancels the import on its first lookup, as an interrupt would.
+type cancelGeocoder struct {
+	cancel context.CancelFunc
+}
+
+// Geocode cancels the import and returns fixed coordinates.
+func (g cancelGeocoder) Geocode(ctx context.Context, place string) (coordinates, error) {
+	g.cancel()
+	return coordinates{Latitude: 30.27, Longitude: -97.74}, nil
+}
+
+// TestProcessCSV_Interrupted tests that cancelling an import stops reading but writes every record already read.
+func TestProcessCSV_Interrupted(t *testing.T) {
+	db := newTestDB(t)
+
+	lines := []string{testCSVHeader}
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, testCSVRow("01-JAN-23", fmt.Sprintf("N%d", i)))
+	}
+	csv := strings.Join(lines, "\n") + "\n"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := importOptions{BatchSize: 3, BufferSize: 1, Workers: 2}
+
+	stats, err := processCSV(ctx, strings.NewReader(csv), db, cancelGeocoder{cancel}, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if stats.Inserted == 0 || stats.Inserted == 1000 || stats.Failed != 0 {
+		t.Errorf("Expected some but not all records inserted and none failed, got %+v", stats)
+	}
+	if got := countRows(t, db, "Accidents"); got != stats.Inserted {
+		t.Errorf("Expected %d accidents, got %d", stats.Inserted, got)
+	}
+	if got := countRows(t, db, "Injuries"); got != stats.Inserted {
+		t.Errorf("Expected one injury per accident, got %d for %d accidents", got, stats.Inserted)
+	}
+}
+
+// TestProcessCSV_IngestionRun tests that accidents are linked to the run that inserted or last changed them.
+func TestProcessCSV_IngestionRun(t *testing.T) {
+	db := newTestDB(t)
+	geocoder := &fakeGeocoder{places: map[string]coordinates{"AUSTIN, Texas, United States": {30.27, -97.74}}}
+
+	importFile := func(rows ...string) {
+		t.Helper()
+		run := &models.IngestionRun{FileName: "faa.csv"}
+		if err := store.StartIngestionRun(context.Background(), db, run); err != nil {
+			t.Fatalf("Failed to start run: %v", err)
+		}
+		csv := strings.Join(append([]string{testCSVHeader}, rows...), "\n") + "\n"
+		if _, err := processCSV(context.Background(), strings.NewReader(csv), db, geocoder, importOptions{BatchSize: 10, RunID: &run.ID}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	importFile(testCSVRow("01-JAN-23", "N1"), testCSVRow("02-JAN-23", "N2"))
+	modified := strings.Replace(testCSVRow("02-JAN-23", "N2"), "AIRCRAFT LANDED HARD.", "AIRCRAFT GROUND LOOPED.", 1)
+	importFile(testCSVRow("01-JAN-23", "N1"), modified, testCSVRow("03-JAN-23", "N3"))
+
+	rows, err := db.Query(`
+		SELECT ac.registration_number, a.ingestion_run_id
+		FROM Accidents a JOIN Aircrafts ac ON ac.id = a.aircraft_id
+		ORDER BY ac.registration_number`)
+	if err != nil {
+		t.Fatalf("Failed to query accidents: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []string
+	for rows.Next() {
+		var registration string
+		var runID int
+		if err := rows.Scan(&registration, &runID); err != nil {
+			t.Fatalf("Failed to scan accident: %v", err)
+		}
+		runs = append(runs, fmt.Sprintf("%s:%d", registration, runID))
+	}
+	if got := strings.Join(runs, ","); got != "N1:1,N2:2,N3:2" {
+		t.Errorf("Expected N1:1,N2:2,N3:2, got %s", got)
+	}
+}
+
+// TestReorderRecords tests that the reorder window sorts records within it and counts the ones that arrive too late.
+func TestReorderRecords(t *testing.T) {
+	tests := []struct {
+		window   int
+		expected string
+		late     int64
+	}{
+		{0, "N1,N3,N2,N4", 0},
+		{1, "N3,N2,N4,N1", 1},
+		{4, "N4,N3,N2,N1", 0},
+	}
+
+	columns, err := newColumnMap(strings.Split(testCSVHeader, ","))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, tt := range tests {
+		in := make(chan *parsedRecord, 4)
+		out := make(chan *parsedRecord, 4)
+		for i, entryDate := range []string{"01-JAN-23", "03-JAN-23", "02-JAN-23", "04-JAN-23"} {
+			record, err := parseRecord(columns, strings.Split(testCSVRow(entryDate, []string{"N1", "N3", "N2", "N4"}[i]), ","))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			record.Line = i + 2
+			in <- record
+		}
+		close(in)
+
+		var progress pipelineProgress
+		if err := reorderRecords(context.Background(), in, out, tt.window, &progress); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		close(out)
+
+		var order []string
+		for record := range out {
+			order = append(order, record.Aircraft.RegistrationNumber)
+		}
+		if got := strings.Join(order, ","); got != tt.expected || progress.late.Load() != tt.late {
+			t.Errorf("With window %d expected %s with %d late, got %s with %d late", tt.window, tt.expected, tt.late, got, progress.late.Load())
+		}
+	}
+}
+
+// TestProcessCSV_ReadError tests that a malformed file stops the pipeline with an error.
+func TestProcessCSV_ReadError(t *testing.T) {
+	db := newTestDB(t)
+
+	csv := testCSVHeader + "\n" + testCSVRow("01-JAN-23", "N1") + "\n\"unterminated\n"
+	_, err := processCSV(context.Background(), strings.NewReader(csv), db, &fakeGeocoder{}, importOptions{BatchSize: 1})
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a CSV parse error, got %v", err)
+	}
+}
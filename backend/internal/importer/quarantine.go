@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reasons a record is quarantined.
+const (
+	reasonFieldCount    = "field_count"    // The record has a different number of fields than the header
+	reasonBadDate       = "bad_date"       // A date could not be parsed
+	reasonBadTime       = "bad_time"       // A time could not be parsed
+	reasonGeocodeFailed = "geocode_failed" // The place could not be geocoded; the record is imported without coordinates
+	reasonWriteFailed   = "write_failed"   // The database rejected the record
+	reasonInvalid       = "invalid"        // Any other parse failure
+)
+
+// fieldError is a parse failure attributed to a column of the record.
+type fieldError struct {
+	Field  string // Header of the failing column, empty when the failure concerns the whole record
+	Reason string
+	Err    error
+}
+
+// Error describes the failure.
+func (e *fieldError) Error() string {
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("error parsing %s: %v", e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *fieldError) Unwrap() error {
+	return e.Err
+}
+
+// quarantine records the CSV records that were rejected, or imported with problems, together with why,
+// so they can be inspected and fixed. It is safe for concurrent use by the pipeline stages.
+type quarantine struct {
+	mu     sync.Mutex
+	w      *csv.Writer // Nil when the records are only counted
+	file   string      // Name of the file being imported, recorded with its records
+	counts map[string]int
+	err    error // First error writing the file
+}
+
+// newQuarantine creates a quarantine writing CSV to w, or only counting records when w is nil.
+// The file has one row per record with its file and line, the reason, the failing field, the error and the raw record.
+func newQuarantine(w io.Writer) *quarantine {
+	q := appendQuarantine(w)
+	if q.w != nil {
+		q.err = q.w.Write([]string{"FILE", "LINE", "REASON", "FIELD", "ERROR", "RECORD"})
+	}
+	return q
+}
+
+// appendQuarantine creates a quarantine like newQuarantine, adding its rows to a file that already has the header.
+func appendQuarantine(w io.Writer) *quarantine {
+	q := &quarantine{counts: make(map[string]int)}
+	if w != nil {
+		q.w = csv.NewWriter(w)
+	}
+	return q
+}
+
+// add quarantines the record starting on line, attributing err to a field when it is a *fieldError.
+// Errors that are not a *fieldError are counted under reason.
+func (q *quarantine) add(line int, fields []string, reason string, err error) {
+	var field string
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		field, reason = fe.Field, fe.Reason
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.counts[reason]++
+	if q.w == nil || q.err != nil {
+		return
+	}
+
+	var raw strings.Builder
+	rw := csv.NewWriter(&raw)
+	rw.Write(fields)
+	rw.Flush()
+
+	q.err = q.w.Write([]string{q.file, strconv.Itoa(line), reason, field, err.Error(), strings.TrimSuffix(raw.String(), "\n")})
+}
+
+// setFile sets the name of the file whose records are quarantined next.
+func (q *quarantine) setFile(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.file = name
+}
+
+// Flush writes any buffered records to the file, returning the first error writing it.
+func (q *quarantine) Flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.w == nil {
+		return nil
+	}
+	q.w.Flush()
+	if q.err == nil {
+		q.err = q.w.Error()
+	}
+	return q.err
+}
+
+// Summary lists the number of quarantined records by reason, such as "bad_date 3, geocode_failed 12".
+func (q *quarantine) Summary() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reasons := make([]string, 0, len(q.counts))
+	for reason := range q.counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s %d", reason, q.counts[reason])
+	}
+	return strings.Join(parts, ", ")
+}
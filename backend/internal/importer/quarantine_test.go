@@ -0,0 +1,111 @@
+// Quarantine tests import CSV files with bad rows and check what was quarantined and why.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProcessCSV_Quarantine tests that rejected records and records imported without coordinates are
+// quarantined with their line, reason, failing field and raw record.
+func TestProcessCSV_Quarantine(t *testing.T) {
+	db := newTestDB(t)
+
+	badTime := strings.Replace(testCSVRow("02-JAN-23", "N2"), "10:00:00Z", "25:00", 1)
+	elsewhere := strings.Replace(testCSVRow("04-JAN-23", "N4"), "AUSTIN", "NOWHERE", 1)
+	csvData := strings.Join([]string{
+		testCSVHeader,
+		testCSVRow("01-JAN-23", "N1"),
+		badTime,
+		testCSVRow("31-FEB-23", "N3"),
+		"No,03-JAN-23,03-JAN-23",
+		elsewhere,
+	}, "\n") + "\n"
+
+	var file bytes.Buffer
+	q := newQuarantine(&file)
+	geocoder := &fakeGeocoder{places: map[string]coordinates{"AUSTIN, Texas, United States": {30.27, -97.74}}}
+	opts := importOptions{BatchSize: 10, Quarantine: q}
+
+	stats, err := processCSV(context.Background(), strings.NewReader(csvData), db, geocoder, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := q.Flush(); err != nil {
+		t.Fatalf("Expected no error flushing the quarantine, got %v", err)
+	}
+
+	expectedStats := Stats{Inserted: 2, Failed: 3}
+	if stats != expectedStats {
+		t.Errorf("Expected stats %+v, got %+v", expectedStats, stats)
+	}
+	if rate := stats.rejectionRate(); rate != 0.6 {
+		t.Errorf("Expected a rejection rate of 0.6, got %v", rate)
+	}
+	if summary := q.Summary(); summary != "bad_date 1, bad_time 1, field_count 1, geocode_failed 1" {
+		t.Errorf("Unexpected summary %q", summary)
+	}
+
+	rows, err := csv.NewReader(&file).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected a valid quarantine CSV, got %v", err)
+	}
+	expected := map[string][2]string{ // Line: reason, field
+		"3": {"bad_time", "EVENT_LCL_TIME"},
+		"4": {"bad_date", "ENTRY_DATE"},
+		"5": {"field_count", ""},
+		"6": {"geocode_failed", "LOC_CITY_NAME"},
+	}
+	if len(rows) != len(expected)+1 {
+		t.Fatalf("Expected a header and %d rows, got %v", len(expected), rows)
+	}
+	for _, row := range rows[1:] {
+		line, reason, field, message, raw := row[1], row[2], row[3], row[4], row[5]
+		if want, ok := expected[line]; !ok || want != [2]string{reason, field} {
+			t.Errorf("Line %s: expected %v, got %s %s", line, want, reason, field)
+		}
+		if message == "" {
+			t.Errorf("Line %s: expected an error message", line)
+		}
+		if line == "3" && raw != badTime {
+			t.Errorf("Line 3: expected the raw record %q, got %q", badTime, raw)
+		}
+	}
+}
+
+// TestOpenQuarantine tests that each run appends its records to the quarantine file under a single header.
+func TestOpenQuarantine(t *testing.T) {
+	cfg := Config{QuarantinePath: filepath.Join(t.TempDir(), "quarantine.csv")}
+
+	for _, name := range []string{"first.csv", "second.csv"} {
+		q, closeQuarantine, err := openQuarantine(cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		q.setFile(name)
+		q.add(2, []string{"No"}, reasonFieldCount, errors.New("wrong number of fields"))
+		if err := q.Flush(); err != nil {
+			t.Fatalf("Expected no error flushing the quarantine, got %v", err)
+		}
+		closeQuarantine()
+	}
+
+	file, err := os.Open(cfg.QuarantinePath)
+	if err != nil {
+		t.Fatalf("Expected the quarantine file, got %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected a valid quarantine CSV, got %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "FILE" || rows[1][0] != "first.csv" || rows[2][0] != "second.csv" {
+		t.Errorf("Expected a header and a row from each run, got %v", rows)
+	}
+}
@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// parseRecord parses a CSV row into the rows to write. Locations are geocoded later in the pipeline,
+// and records are written by a batchWriter as upserts so that re-running an import only writes records
+// that are new or have changed.
+func parseRecord(columns *columnMap, fields []string) (*parsedRecord, error) {
+	record, err := newFAARecord(columns, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	aircraft, accident, location, err := parseRecordToIncident(record)
+	if err != nil {
+		return nil, err
+	}
+
+	injuries, err := extractInjuriesFromRecord(record, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsedRecord{Fields: fields, Aircraft: aircraft, Accident: accident, Location: location, Injuries: injuries}, nil
+}
+
+// parseRecordToIncident converts a CSV record to an Accident struct.
+func parseRecordToIncident(record faaRecord) (*models.Aircraft, *models.Accident, *models.Location, error) {
+	aircraft := &models.Aircraft{
+		RegistrationNumber: record.get(colRegistration),
+		AircraftMakeName:   record.get(colMakeName),
+		AircraftModelName:  record.get(colModelName),
+		AircraftOperator:   record.get(colOperator),
+	}
+
+	entryDate, err := parseDate(record.get(colEntryDate))
+	if err != nil {
+		return nil, nil, nil, &fieldError{Field: faaColumnHeaders[colEntryDate], Reason: reasonBadDate, Err: err}
+	}
+	eventLocalDate, err := parseDate(record.get(colEventLocalDate))
+	if err != nil {
+		return nil, nil, nil, &fieldError{Field: faaColumnHeaders[colEventLocalDate], Reason: reasonBadDate, Err: err}
+	}
+	eventLocalTime, err := parseTime(record.get(colEventLocalTime))
+	if err != nil {
+		return nil, nil, nil, &fieldError{Field: faaColumnHeaders[colEventLocalTime], Reason: reasonBadTime, Err: err}
+	}
+
+	// Process the remark text
+	city := record.get(colCityName)
+	state := record.get(colStateName)
+	remarkText := ProcessRemark(record.get(colRemarkText), city, state)
+
+	incident := &models.Accident{
+		Updated:                   record.get(colUpdated),
+		EntryDate:                 entryDate,
+		EventLocalDate:            eventLocalDate,
+		EventLocalTime:            eventLocalTime,
+		RemarkText:                remarkText,
+		EventTypeDescription:      record.get(colEventType),
+		FSDODescription:           record.get(colFSDO),
+		FlightNumber:              record.get(colFlightNumber),
+		AircraftMissingFlag:       record.get(colMissingFlag),
+		AircraftDamageDescription: record.get(colDamage),
+		FlightActivity:            record.get(colFlightActivity),
+		FlightPhase:               record.get(colFlightPhase),
+		FARPart:                   normalizeFARPart(record.get(colFARPart)),
+		FatalFlag:                 record.get(colFatalFlag),
+		Source:                    store.SourceFAA,
+	}
+
+	// Coordinates are filled in afterwards by geocodeLocation.
+	location := &models.Location{
+		CityName:    city,
+		StateName:   state,
+		CountryName: record.get(colCountryName),
+	}
+
+	return aircraft, incident, location, nil
+}
+
+// ExtractCityState extracts the city and state from the remark text.
+func ExtractCityState(remarkText string) (string, string, string) {
+	// Define a regex pattern to capture city and state
+	pattern := `, ([A-Za-z\s]+), ([A-Z]{2})\.`
+	re := regexp.MustCompile(pattern)
+
+	// Find and extract city and state
+	match := re.FindStringSubmatch(remarkText)
+	if len(match) == 3 {
+		city := match[1]
+		state := match[2]
+		remarkText = strings.TrimSuffix(remarkText, match[0])
+		return remarkText, city, state
+	}
+
+	return remarkText, "", ""
+}
+
+// ProcessRemark processes the remark text by ensuring it ends with the provided city and state.
+func ProcessRemark(remarkText, city, state string) string {
+	// Extract any existing city and state
+	remarkText, _, _ = ExtractCityState(remarkText)
+
+	// Ensure the remarkText ends with a period
+	if !strings.HasSuffix(remarkText, ".") {
+		remarkText = strings.TrimSpace(remarkText) + "."
+	}
+
+	return fmt.Sprintf("%s %s, %s.", remarkText, strings.ToUpper(city), strings.ToUpper(state))
+}
+
+// extractInjuriesFromRecord reads the injury count columns, one per person type and severity.
+func extractInjuriesFromRecord(record faaRecord, accidentID int) ([]*models.Injury, error) {
+	var injuries []*models.Injury
+
+	for i, column := range record.columns.injuries {
+		value := record.fields[record.columns.injuryAt[i]]
+		if value == "" {
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("Error converting string to int for %s %s: %v", column.PersonType, column.InjurySeverity, err)
+			continue
+		}
+		injuries = append(injuries, &models.Injury{
+			PersonType:     column.PersonType,
+			InjurySeverity: column.InjurySeverity,
+			Count:          count,
+			AccidentID:     accidentID,
+		})
+	}
+
+	return injuries, nil
+}
+
+// atoiSafe converts string to int, returns 0 if conversion fails or the string is empty.
+func AtoiSafe(s string) int {
+	if s == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		log.Printf("Error converting string to int: %v", err)
+		return 0
+	}
+	return value
+}
+
+// dateLayout is the format dates are written to the database in.
+const dateLayout = "2006-01-02"
+
+// Helper function to parse a date string into time.Time, returns time.Time and error.
+func parseDate(dateStr string) (time.Time, error) {
+	layout := "02-Jan-06"
+	t, err := time.Parse(layout, dateStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing date '%s': %v", dateStr, err)
+	}
+	return t, nil
+}
+
+// Helper function to format a time string into time.Time, returns time.Time and error.
+func parseTime(timeStr string) (string, error) {
+	layout := "15:04:05Z"
+	t, err := time.Parse(layout, timeStr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing time '%s': %v", timeStr, err)
+	}
+	return t.Format("15:04:05"), nil
+}
+
+// normalizeFARPart writes a FAR part the way the FAA data does, with numeric parts padded to three digits such as
+// "091", so accidents from every source can be filtered by the same value.
+func normalizeFARPart(part string) string {
+	part = strings.ToUpper(strings.TrimSpace(part))
+	if n, err := strconv.Atoi(part); err == nil && n >= 0 {
+		return fmt.Sprintf("%03d", n)
+	}
+	return part
+}
@@ -0,0 +1,317 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// Files of the FAA releasable aircraft registry read by the importer, as found in ReleasableAircraft.zip.
+const (
+	registryMaster   = "MASTER"  // One row per registered aircraft, with its owner
+	registryAircraft = "ACFTREF" // Aircraft models, by manufacturer, model and series code
+	registryEngine   = "ENGINE"  // Engine models, by manufacturer and model code
+)
+
+// registryTables lists the files in the order they are read; MASTER and ACFTREF are required.
+var registryTables = []string{registryMaster, registryAircraft, registryEngine}
+
+// registryDetails holds the registration details of an aircraft; zero values are unknown.
+type registryDetails struct {
+	yearManufactured int
+	serialNumber     string
+	engineType       string
+	numberOfSeats    int
+	category         string
+	ownerCity        string
+	ownerState       string
+}
+
+// registryModel is an aircraft model of the ACFTREF file.
+type registryModel struct {
+	category      string
+	numberOfSeats int
+}
+
+// RunRegistry enriches the aircraft in the database with the FAA releasable aircraft registry extracted into dir.
+// Aircraft are matched by N-number, and every aircraft row with the registration is updated; aircraft missing from
+// the registry keep the details they have. The files are recorded as an ingestion run counting the aircraft updated
+// and unchanged, and skipped when a successful run imported the same files, unless cfg.Force is set. Dry runs are
+// not supported.
+func RunRegistry(ctx context.Context, db *store.DB, dir string, cfg Config) (*Result, error) {
+	if cfg.DryRun {
+		return nil, errors.New("dry runs are not supported for the aircraft registry")
+	}
+
+	paths, err := findTables(dir, ".txt", registryTables, registryMaster, registryAircraft)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := hashTables(registryTables, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash registry files: %w", err)
+	}
+
+	result := &Result{Name: filepath.Base(dir)}
+	previous, err := previousImport(db, result.Name, sum, cfg)
+	if err != nil || previous != nil {
+		result.Skipped = previous
+		return result, err
+	}
+
+	run := &models.IngestionRun{SourceURL: cfg.SourceURL, FileName: result.Name, FileSHA256: sum}
+	if err := store.StartIngestionRun(context.Background(), db, run); err != nil {
+		return nil, fmt.Errorf("failed to start ingestion run: %w", err)
+	}
+	result.Run = run
+
+	result.Stats, err = processRegistry(ctx, paths, db, cfg.BatchSize)
+	log.Printf("Processed registered aircraft: %s", result.Stats)
+	finishIngestionRun(db, run, result.Stats, err)
+	return result, err
+}
+
+// processRegistry matches the MASTER file with the aircraft in the database and writes the details that changed,
+// batchSize aircraft per transaction. Cancelling ctx stops between transactions.
+func processRegistry(ctx context.Context, paths map[string]string, db *store.DB, batchSize int) (Stats, error) {
+	var stats Stats
+	current, err := loadRegisteredAircraft(ctx, db)
+	if err != nil {
+		return stats, err
+	}
+	aircraftModels, err := loadRegistryModels(paths[registryAircraft])
+	if err != nil {
+		return stats, err
+	}
+	engines := make(map[string]string)
+	if path, ok := paths[registryEngine]; ok {
+		if engines, err = loadRegistryEngines(path); err != nil {
+			return stats, err
+		}
+	}
+
+	updates := make(map[int]registryDetails)
+	var ids []int
+	required := []string{"N-NUMBER", "SERIAL NUMBER", "MFR MDL CODE", "YEAR MFR", "CITY", "STATE"}
+	err = readCSVTable(paths[registryMaster], registryMaster, required, func(t *csvTable, _ int, fields []string) error {
+		aircraft, ok := current[strings.ToUpper(t.get(fields, "N-NUMBER"))]
+		if !ok {
+			return nil
+		}
+		model := aircraftModels[t.get(fields, "MFR MDL CODE")]
+		details := registryDetails{
+			serialNumber:  t.get(fields, "SERIAL NUMBER"),
+			engineType:    registryCode(registryEngineTypes, t.get(fields, "TYPE ENGINE")),
+			numberOfSeats: model.numberOfSeats,
+			category:      model.category,
+			ownerCity:     t.get(fields, "CITY"),
+			ownerState:    t.get(fields, "STATE"),
+		}
+		details.yearManufactured, _ = strconv.Atoi(t.get(fields, "YEAR MFR"))
+		if details.engineType == "" {
+			details.engineType = engines[t.get(fields, "ENG MFR MDL")]
+		}
+		if details.category == "" {
+			details.category = registryCode(registryAircraftTypes, t.get(fields, "TYPE AIRCRAFT"))
+		}
+
+		for id, existing := range aircraft {
+			if existing == details {
+				stats.Unchanged++
+				continue
+			}
+			if _, ok := updates[id]; !ok {
+				ids = append(ids, id)
+			}
+			updates[id] = details
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	sort.Ints(ids)
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	for start := 0; start < len(ids); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := writeRegistryDetails(ctx, db, ids[start:end], updates); err != nil {
+			return stats, err
+		}
+		stats.Updated += end - start
+	}
+	return stats, nil
+}
+
+// loadRegisteredAircraft returns the current details of the aircraft with a US registration, by N-number without
+// its N prefix and aircraft ID.
+func loadRegisteredAircraft(ctx context.Context, db *store.DB) (map[string]map[int]registryDetails, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, registration_number, COALESCE(year_manufactured, 0), COALESCE(serial_number, ''),
+			COALESCE(engine_type, ''), COALESCE(number_of_seats, 0), COALESCE(aircraft_category, ''),
+			COALESCE(owner_city, ''), COALESCE(owner_state, '')
+		FROM Aircrafts
+		WHERE registration_number IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying aircraft: %w", err)
+	}
+	defer rows.Close()
+
+	aircraft := make(map[string]map[int]registryDetails)
+	for rows.Next() {
+		var id int
+		var registration string
+		var d registryDetails
+		err := rows.Scan(&id, &registration, &d.yearManufactured, &d.serialNumber, &d.engineType,
+			&d.numberOfSeats, &d.category, &d.ownerCity, &d.ownerState)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning aircraft: %w", err)
+		}
+		number := registryNumber(registration)
+		if number == "" {
+			continue
+		}
+		if aircraft[number] == nil {
+			aircraft[number] = make(map[int]registryDetails)
+		}
+		aircraft[number][id] = d
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying aircraft: %w", err)
+	}
+	return aircraft, nil
+}
+
+// loadRegistryModels reads the aircraft models of the ACFTREF file, by code.
+func loadRegistryModels(path string) (map[string]registryModel, error) {
+	aircraftModels := make(map[string]registryModel)
+	err := readCSVTable(path, registryAircraft, []string{"CODE", "TYPE-ACFT", "NO-SEATS"}, func(t *csvTable, _ int, fields []string) error {
+		seats, _ := strconv.Atoi(t.get(fields, "NO-SEATS"))
+		aircraftModels[t.get(fields, "CODE")] = registryModel{
+			category:      registryCode(registryAircraftTypes, t.get(fields, "TYPE-ACFT")),
+			numberOfSeats: seats,
+		}
+		return nil
+	})
+	return aircraftModels, err
+}
+
+// loadRegistryEngines reads the engine types of the ENGINE file, by code.
+func loadRegistryEngines(path string) (map[string]string, error) {
+	engines := make(map[string]string)
+	err := readCSVTable(path, registryEngine, []string{"CODE", "TYPE"}, func(t *csvTable, _ int, fields []string) error {
+		engines[t.get(fields, "CODE")] = registryCode(registryEngineTypes, t.get(fields, "TYPE"))
+		return nil
+	})
+	return engines, err
+}
+
+// writeRegistryDetails updates the aircraft with the given IDs in a single transaction. Unknown details are
+// written as NULL.
+func writeRegistryDetails(ctx context.Context, db *store.DB, ids []int, updates map[int]registryDetails) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		d := updates[id]
+		_, err := tx.ExecContext(ctx, `
+			UPDATE Aircrafts
+			SET year_manufactured = ?, serial_number = ?, engine_type = ?, number_of_seats = ?,
+				aircraft_category = ?, owner_city = ?, owner_state = ?
+			WHERE id = ?`,
+			nullIfZero(d.yearManufactured), nullIfEmpty(d.serialNumber), nullIfEmpty(d.engineType),
+			nullIfZero(d.numberOfSeats), nullIfEmpty(d.category), nullIfEmpty(d.ownerCity), nullIfEmpty(d.ownerState), id)
+		if err != nil {
+			return fmt.Errorf("error updating aircraft %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// registryNumber returns the N-number of a US registration as written in the registry, without the N prefix, or an
+// empty string for other registrations. "N12345", "n-12345" and "12345" all return "12345"; N-numbers start with a
+// digit, which tells them apart from foreign registrations such as "C-GABC".
+func registryNumber(registration string) string {
+	number := store.NormalizeRegistration(registration)
+	if strings.HasPrefix(number, "N") {
+		number = number[1:]
+	}
+	if number == "" || number[0] < '1' || number[0] > '9' {
+		return ""
+	}
+	return number
+}
+
+// nullIfZero returns nil for zero, so unknown numbers are stored as NULL.
+func nullIfZero(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// nullIfEmpty returns nil for an empty string, so unknown values are stored as NULL.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// registryCode returns the description of a registry code, or an empty string for unknown codes.
+func registryCode(codes map[string]string, code string) string {
+	return codes[strings.ToUpper(code)]
+}
+
+// registryAircraftTypes describes the TYPE-ACFT codes of ACFTREF and the TYPE AIRCRAFT codes of MASTER.
+var registryAircraftTypes = map[string]string{
+	"1": "Glider",
+	"2": "Balloon",
+	"3": "Blimp/Dirigible",
+	"4": "Fixed wing single engine",
+	"5": "Fixed wing multi engine",
+	"6": "Rotorcraft",
+	"7": "Weight-shift-control",
+	"8": "Powered parachute",
+	"9": "Gyroplane",
+	"H": "Hybrid lift",
+	"O": "Other",
+}
+
+// registryEngineTypes describes the TYPE codes of ENGINE and the TYPE ENGINE codes of MASTER.
+var registryEngineTypes = map[string]string{
+	"0":  "None",
+	"1":  "Reciprocating",
+	"2":  "Turbo-prop",
+	"3":  "Turbo-shaft",
+	"4":  "Turbo-jet",
+	"5":  "Turbo-fan",
+	"6":  "Ramjet",
+	"7":  "2 Cycle",
+	"8":  "4 Cycle",
+	"9":  "Unknown",
+	"10": "Electric",
+	"11": "Rotary",
+}
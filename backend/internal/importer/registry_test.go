@@ -0,0 +1,94 @@
+// Registry tests enrich aircraft from a small extract of the FAA aircraft registry in testdata/registry.
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// TestRunRegistry tests that aircraft are matched by N-number however their registration is written, that aircraft
+// missing from the registry keep their details, and that an unchanged registry is skipped.
+func TestRunRegistry(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec(`INSERT INTO Aircrafts (id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator, owner_city) VALUES
+		(1, 'N12345', 'CESSNA', '172', '', NULL),
+		(2, 'n-12345', 'CESSNA', '172M', '', NULL),
+		(3, 'N777AB', 'PIPER', 'PA28', '', NULL),
+		(4, 'C-GABC', 'CESSNA', '172', '', NULL),
+		(5, 'N999', 'BEECH', '35', '', 'WICHITA')`)
+	if err != nil {
+		t.Fatalf("Failed to seed aircraft: %v", err)
+	}
+
+	cfg := Config{BatchSize: 2}
+	result, err := RunRegistry(context.Background(), db, "testdata/registry", cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Stats.Updated != 3 || result.Stats.Unchanged != 0 || result.Run == nil || result.Run.FileName != "registry" {
+		t.Fatalf("Expected a run updating 3 aircraft, got %+v, %+v", result.Stats, result.Run)
+	}
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		{1, "1975 17265432 Reciprocating 4 Fixed wing single engine AUSTIN TX"},
+		{2, "1975 17265432 Reciprocating 4 Fixed wing single engine AUSTIN TX"},
+		// No year or engine type in MASTER, so the engine type comes from ENGINE
+		{3, "0 28-7816001 Reciprocating 4 Fixed wing single engine DENVER CO"},
+		{4, "0   0   "},
+		{5, "0   0  WICHITA "},
+	}
+	for _, tt := range tests {
+		var year, seats sql.NullInt64
+		var serial, engine, category, city, state sql.NullString
+		err := db.QueryRow(`
+			SELECT year_manufactured, serial_number, engine_type, number_of_seats, aircraft_category, owner_city, owner_state
+			FROM Aircrafts WHERE id = ?`, tt.id).Scan(&year, &serial, &engine, &seats, &category, &city, &state)
+		if err != nil {
+			t.Fatalf("Failed to query aircraft %d: %v", tt.id, err)
+		}
+		got := fmt.Sprintf("%d %s %s %d %s %s %s", year.Int64, serial.String, engine.String, seats.Int64, category.String, city.String, state.String)
+		if got != tt.expected {
+			t.Errorf("Expected aircraft %d to be %q, got %q", tt.id, tt.expected, got)
+		}
+	}
+
+	runID := result.Run.ID
+	result, err = RunRegistry(context.Background(), db, "testdata/registry", cfg)
+	if err != nil || result.Skipped == nil || result.Skipped.ID != runID || result.Run != nil {
+		t.Errorf("Expected the unchanged registry to be skipped, got %+v, %v", result, err)
+	}
+
+	cfg.Force = true
+	result, err = RunRegistry(context.Background(), db, "testdata/registry", cfg)
+	if err != nil || result.Stats.Updated != 0 || result.Stats.Unchanged != 3 {
+		t.Errorf("Expected a forced run to leave 3 aircraft unchanged, got %+v, %v", result.Stats, err)
+	}
+}
+
+// TestRegistryNumber tests that US registrations are reduced to the registry's N-number and others are ignored.
+func TestRegistryNumber(t *testing.T) {
+	tests := []struct {
+		registration string
+		expected     string
+	}{
+		{"N12345", "12345"},
+		{"12345", "12345"},
+		{"n-12345", "12345"},
+		{" N 777AB ", "777AB"},
+		{"C-GABC", ""},
+		{"N", ""},
+		{"N0123", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := registryNumber(tt.registration); got != tt.expected {
+			t.Errorf("Expected %q to be %q, got %q", tt.registration, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source is a CSV file to import: a file on disk, a gzip-compressed file, an entry of a zip archive or
+// standard input.
+type Source struct {
+	Name string // Shown in logs and recorded as the ingestion run's file name
+	Path string // File on disk checked against the downloader's checksum, empty for compressed and archived files
+	open func() (io.ReadCloser, error)
+
+	// streamed is set for standard input, which can only be read once: it is not scanned before the import but
+	// hashed while it is imported.
+	streamed bool
+}
+
+// Open opens the CSV contents of the source. Files can be opened more than once, standard input only once.
+func (s Source) Open() (io.ReadCloser, error) {
+	return s.open()
+}
+
+// FileSource returns the source for an uncompressed CSV file.
+func FileSource(path string) Source {
+	return Source{Name: filepath.Base(path), Path: path, open: func() (io.ReadCloser, error) { return os.Open(path) }}
+}
+
+// Sources expands paths into the CSV files they contain. A path is a CSV file, a .gz file, a .zip archive whose
+// .csv entries are imported, a directory whose CSV, .gz and .zip files are imported, or "-" for standard input,
+// which is streamed into the import.
+func Sources(paths []string, stdin io.Reader) ([]Source, error) {
+	var sources []Source
+	for _, path := range paths {
+		if path == "-" {
+			sources = append(sources, Source{Name: "stdin", streamed: true, open: func() (io.ReadCloser, error) {
+				return io.NopCloser(stdin), nil
+			}})
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			found, err := fileSources(path)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, found...)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isImportable(entry.Name()) {
+				continue
+			}
+			found, err := fileSources(filepath.Join(path, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, found...)
+		}
+	}
+	return sources, nil
+}
+
+// isImportable reports whether a file in a directory is imported, by its extension.
+func isImportable(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".zip")
+}
+
+// fileSources returns the sources in a file, by its extension.
+func fileSources(path string) ([]Source, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return []Source{{Name: filepath.Base(path), open: func() (io.ReadCloser, error) { return openGzip(path) }}}, nil
+	case ".zip":
+		return zipSources(path)
+	default:
+		return []Source{FileSource(path)}, nil
+	}
+}
+
+// openGzip opens a gzip-compressed file for reading its decompressed contents.
+func openGzip(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return readCloser{gz, func() error { gz.Close(); return file.Close() }}, nil
+}
+
+// zipSources returns a source for each CSV file in a zip archive.
+func zipSources(path string) ([]Source, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer archive.Close()
+
+	var sources []Source
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(entry.Name), ".csv") {
+			continue
+		}
+		entryName := entry.Name
+		sources = append(sources, Source{
+			Name: filepath.Base(path) + "/" + entryName,
+			open: func() (io.ReadCloser, error) { return openZipEntry(path, entryName) },
+		})
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no CSV files in %s", path)
+	}
+	return sources, nil
+}
+
+// openZipEntry opens a file in a zip archive.
+func openZipEntry(path, name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := archive.Open(name)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return readCloser{entry, func() error { entry.Close(); return archive.Close() }}, nil
+}
+
+// readCloser reads from a reader and closes the resources it reads from.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// scannedSource is a source with what a first pass over it found.
+type scannedSource struct {
+	Source
+	SHA256      string    // Empty for standard input until it is imported
+	LatestEntry time.Time // Newest ENTRY_DATE of the file, zero if none could be parsed
+}
+
+// scanSource reads a file once to compute its SHA-256 and find the newest ENTRY_DATE of all its records, checking
+// its header and, for files from the downloader, its checksum. Records are read one at a time and nothing is kept in
+// memory. Standard input is not read, as it is streamed into the import.
+func scanSource(src Source) (*scannedSource, error) {
+	if src.streamed {
+		return &scannedSource{Source: src}, nil
+	}
+
+	r, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(r, hash))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	columns, err := newColumnMap(header)
+	if err != nil {
+		return nil, err
+	}
+
+	scanned := &scannedSource{Source: src}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %w", err)
+		}
+		record, err := newFAARecord(columns, fields)
+		if err != nil {
+			continue // Quarantined during the import
+		}
+		if date, err := parseDate(record.get(colEntryDate)); err == nil && date.After(scanned.LatestEntry) {
+			scanned.LatestEntry = date
+		}
+	}
+	// Hash anything the CSV reader did not consume, such as a trailing newline
+	if _, err := io.Copy(hash, r); err != nil {
+		return nil, err
+	}
+	scanned.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if src.Path != "" {
+		if err := verifyChecksum(src.Path, scanned.SHA256); err != nil {
+			return nil, err
+		}
+	}
+	return scanned, nil
+}
+
+// sortChronologically orders sources by their newest ENTRY_DATE, so later exports update the records of earlier
+// ones, keeping the order given for sources from the same date. Standard input cannot be dated before it is read
+// and comes last.
+func sortChronologically(sources []*scannedSource) {
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].streamed != sources[j].streamed {
+			return sources[j].streamed
+		}
+		return sources[i].LatestEntry.Before(sources[j].LatestEntry)
+	})
+}
@@ -0,0 +1,139 @@
+// Source tests import directories of plain, gzip-compressed and zipped CSV files and standard input.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestFile writes data to name in dir, failing the test on error.
+func writeTestFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+// TestRunAll_Directory tests that the CSV, .gz and .zip files of a directory are imported oldest first, each
+// as its own ingestion run, and skipped when imported again.
+func TestRunAll_Directory(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := func(entryDate, registration string) []byte {
+		return []byte(testCSVHeader + "\n" + testCSVRow(entryDate, registration) + "\n")
+	}
+
+	writeTestFile(t, dir, "a.csv", csvFile("01-JAN-23", "N3"))
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	entry, _ := zw.Create("faa.csv")
+	entry.Write(csvFile("01-JAN-21", "N1"))
+	zw.Close()
+	writeTestFile(t, dir, "b.zip", zipped.Bytes())
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(csvFile("01-JAN-22", "N2"))
+	gw.Close()
+	writeTestFile(t, dir, "c.csv.gz", gzipped.Bytes())
+
+	writeTestFile(t, dir, "notes.txt", []byte("not imported"))
+
+	sources, err := Sources([]string{dir}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	db := newTestDB(t)
+	cfg := Config{BatchSize: 10, Geocoder: "gazetteer", GazetteerPaths: []string{"testdata/geonames_sample.txt"}, MaxRejected: 0.05}
+	results, err := RunAll(context.Background(), db, sources, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"b.zip/faa.csv", "c.csv.gz", "a.csv"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Name != expected[i] {
+			t.Errorf("Expected file %d to be %s, got %s", i, expected[i], result.Name)
+		}
+		if result.Run == nil || result.Run.ID != i+1 || result.Run.FileName != expected[i] || result.Stats.Inserted != 1 {
+			t.Errorf("Expected run %d inserting 1 record from %s, got %+v", i+1, expected[i], result.Run)
+		}
+	}
+
+	results, err = RunAll(context.Background(), db, sources, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, result := range results {
+		if result.Skipped == nil || result.Skipped.ID != i+1 || result.Run != nil {
+			t.Errorf("Expected %s to be skipped as imported by run %d, got %+v", result.Name, i+1, result)
+		}
+	}
+}
+
+// TestRunAll_Stdin tests that standard input is imported and hashed as it is read, and that a source that cannot
+// be read stops the import before anything is written.
+func TestRunAll_Stdin(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "bad.csv", []byte("NOT,THE,FAA,HEADER\n"))
+
+	input := testCSVHeader + "\n" + testCSVRow("01-JAN-23", "N1") + "\n"
+	stdin := strings.NewReader(input)
+	sources, err := Sources([]string{"-", filepath.Join(dir, "bad.csv")}, stdin)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	db := newTestDB(t)
+	cfg := Config{BatchSize: 10, Geocoder: "gazetteer", GazetteerPaths: []string{"testdata/geonames_sample.txt"}, MaxRejected: 0.05}
+	if results, err := RunAll(context.Background(), db, sources, cfg); err == nil || !strings.Contains(err.Error(), "bad.csv") || len(results) != 0 {
+		t.Errorf("Expected bad.csv to be refused before importing, got %d results, %v", len(results), err)
+	}
+
+	results, err := RunAll(context.Background(), db, sources[:1], cfg)
+	if err != nil || len(results) != 1 || results[0].Name != "stdin" || results[0].Stats.Inserted != 1 {
+		t.Fatalf("Expected 1 record inserted from stdin, got %+v, %v", results, err)
+	}
+	if sum := sha256.Sum256([]byte(input)); results[0].Run.FileSHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the SHA-256 of stdin to be recorded, got %q", results[0].Run.FileSHA256)
+	}
+}
+
+// TestScanSource tests that a file is dated by the newest ENTRY_DATE of all its records, however far into the file
+// it is, and hashed in the same pass.
+func TestScanSource(t *testing.T) {
+	rows := []string{testCSVHeader}
+	for i := 0; i < 1500; i++ {
+		rows = append(rows, testCSVRow("01-JAN-20", "N1"))
+	}
+	rows = append(rows, testCSVRow("15-MAR-23", "N2"))
+	data := []byte(strings.Join(rows, "\n") + "\n")
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "oldest_first.csv", data)
+
+	scanned, err := scanSource(FileSource(filepath.Join(dir, "oldest_first.csv")))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC); !scanned.LatestEntry.Equal(expected) {
+		t.Errorf("Expected the latest entry %v, got %v", expected, scanned.LatestEntry)
+	}
+	sum := sha256.Sum256(data)
+	if expected := hex.EncodeToString(sum[:]); scanned.SHA256 != expected {
+		t.Errorf("Expected SHA-256 %s, got %s", expected, scanned.SHA256)
+	}
+}
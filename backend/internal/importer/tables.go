@@ -0,0 +1,127 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findTables returns the path of each table file in dir, named after the table with the extension ext and matched
+// case-insensitively, returning an error when a required table is missing.
+func findTables(dir, ext string, tables []string, required ...string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		for _, table := range tables {
+			if name == strings.ToLower(table+ext) {
+				paths[table] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	for _, table := range required {
+		if paths[table] == "" {
+			return nil, fmt.Errorf("%s has no %s%s", dir, table, ext)
+		}
+	}
+	return paths, nil
+}
+
+// hashTables returns the SHA-256 of the tables' names and SHA-256s, identifying the set of files imported.
+func hashTables(tables []string, paths map[string]string) (string, error) {
+	combined := sha256.New()
+	for _, table := range tables {
+		path, ok := paths[table]
+		if !ok {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(combined, "%s %x\n", table, hash.Sum(nil))
+	}
+	return hex.EncodeToString(combined.Sum(nil)), nil
+}
+
+// csvTable locates the columns of a table export by name.
+type csvTable struct {
+	name    string
+	columns map[string]int
+}
+
+// newCSVTable reads the table's header, returning an error when a required column is missing.
+func newCSVTable(name string, header []string, required ...string) (*csvTable, error) {
+	t := &csvTable{name: name, columns: make(map[string]int)}
+	for i, column := range header {
+		t.columns[normalizeHeader(column)] = i
+	}
+	var missing []string
+	for _, column := range required {
+		if _, ok := t.columns[normalizeHeader(column)]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the %s table has no %s column", name, strings.Join(missing, ", "))
+	}
+	return t, nil
+}
+
+// get returns the trimmed value of a column, or an empty string when the table has no such column.
+func (t *csvTable) get(fields []string, column string) string {
+	i, ok := t.columns[normalizeHeader(column)]
+	if !ok || i >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+// readCSVTable calls fn with each row of a table file and the line it starts on.
+func readCSVTable(path, name string, required []string, fn func(t *csvTable, line int, fields []string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading %s header: %w", name, err)
+	}
+	t, err := newCSVTable(name, header, required...)
+	if err != nil {
+		return err
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if err := fn(t, line, fields); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,574 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// recordOutcome describes what writing a record did to the database.
+type recordOutcome int
+
+const (
+	outcomeInserted recordOutcome = iota
+	outcomeUpdated
+	outcomeUnchanged
+)
+
+// Stats counts the outcome of every record in a run.
+type Stats struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Failed    int
+}
+
+// rejectionRate returns the fraction of the records that failed.
+func (s Stats) rejectionRate() float64 {
+	total := s.Inserted + s.Updated + s.Unchanged + s.Failed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(total)
+}
+
+// add counts a record outcome.
+func (s *Stats) add(outcome recordOutcome) {
+	switch outcome {
+	case outcomeInserted:
+		s.Inserted++
+	case outcomeUpdated:
+		s.Updated++
+	case outcomeUnchanged:
+		s.Unchanged++
+	}
+}
+
+// String summarizes the counts for logging.
+func (s Stats) String() string {
+	return fmt.Sprintf("%d inserted, %d updated, %d unchanged, %d failed", s.Inserted, s.Updated, s.Unchanged, s.Failed)
+}
+
+// parsedRecord holds the rows parsed from a single CSV record.
+type parsedRecord struct {
+	Line     int      // Line of the CSV file the record starts on
+	Fields   []string // Raw CSV record, for the quarantine
+	Aircraft *models.Aircraft
+	Accident *models.Accident
+	Location *models.Location
+	Injuries []*models.Injury
+}
+
+// batchWriter buffers parsed records and writes each batch in a single transaction.
+// When a batch fails it is rolled back and retried one record per transaction,
+// so a single bad record only fails itself.
+type batchWriter struct {
+	db         *store.DB
+	size       int
+	runID      *int                                                                                      // Ingestion run the written accidents are linked to, nil when the import is not recorded
+	write      func(ctx context.Context, db *store.DB, records []*parsedRecord) ([]recordOutcome, error) // writeBatch, or diffBatch for dry runs
+	quarantine *quarantine                                                                               // Receives the records that fail
+	pending    []*parsedRecord
+	stats      Stats
+}
+
+// newBatchWriter creates a batchWriter that commits every size records.
+func newBatchWriter(db *store.DB, size int, q *quarantine) *batchWriter {
+	if size < 1 {
+		size = 1
+	}
+	return &batchWriter{db: db, size: size, write: writeBatch, quarantine: q}
+}
+
+// add buffers a record, writing the batch once it is full.
+func (b *batchWriter) add(ctx context.Context, record *parsedRecord) {
+	record.Accident.IngestedBy = b.runID
+	b.pending = append(b.pending, record)
+	if len(b.pending) >= b.size {
+		b.flush(ctx)
+	}
+}
+
+// flush writes the buffered records.
+func (b *batchWriter) flush(ctx context.Context) {
+	if len(b.pending) == 0 {
+		return
+	}
+	defer func() { b.pending = b.pending[:0] }()
+
+	outcomes, err := b.write(ctx, b.db, b.pending)
+	if err == nil {
+		for _, outcome := range outcomes {
+			b.stats.add(outcome)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		// The import was aborted; the transaction was rolled back and retrying would fail too.
+		log.Printf("Discarded batch of %d records: %v", len(b.pending), err)
+		return
+	}
+
+	if len(b.pending) == 1 {
+		b.fail(b.pending[0], err)
+		return
+	}
+
+	log.Printf("Failed to write batch of %d records, retrying one at a time: %v", len(b.pending), err)
+	for _, record := range b.pending {
+		outcomes, err := b.write(ctx, b.db, []*parsedRecord{record})
+		if err != nil {
+			b.fail(record, err)
+			continue
+		}
+		b.stats.add(outcomes[0])
+	}
+}
+
+// fail counts and quarantines a record the database rejected.
+func (b *batchWriter) fail(record *parsedRecord, err error) {
+	log.Printf("Failed to write record on line %d: %v", record.Line, err)
+	b.stats.Failed++
+	b.quarantine.add(record.Line, record.Fields, reasonWriteFailed, err)
+}
+
+// writeBatch upserts the records in a single transaction, rolling it back if any record fails.
+func writeBatch(ctx context.Context, db *store.DB, records []*parsedRecord) (outcomes []recordOutcome, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	w, err := newRecordWriter(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	for _, record := range records {
+		outcome, err := w.writeRecord(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return outcomes, nil
+}
+
+// recordWriter upserts records within a transaction using statements prepared once per transaction.
+type recordWriter struct {
+	tx *store.Tx
+
+	selectAircraftByRegistration *sql.Stmt
+	selectAircraftByFields       *sql.Stmt
+	insertAircraft               *store.InsertStmt
+	updateAircraft               *sql.Stmt
+	selectLocation               *sql.Stmt
+	insertLocation               *store.InsertStmt
+	updateLocation               *sql.Stmt
+	selectAccident               *sql.Stmt
+	selectAccidentBySource       *sql.Stmt
+	insertAccident               *store.InsertStmt
+	updateAccident               *sql.Stmt
+	selectInjuries               *sql.Stmt
+	deleteInjuries               *sql.Stmt
+
+	stmts []interface{ Close() error } // Every prepared statement, for Close
+}
+
+// newRecordWriter prepares the importer's statements in the transaction.
+func newRecordWriter(ctx context.Context, tx *store.Tx) (*recordWriter, error) {
+	w := &recordWriter{tx: tx}
+
+	prepare := func(dst **sql.Stmt, query string) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error preparing statement: %w", err)
+		}
+		*dst = stmt
+		w.stmts = append(w.stmts, stmt)
+		return nil
+	}
+	prepareInsert := func(dst **store.InsertStmt, query string) error {
+		stmt, err := tx.PrepareInsertContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error preparing statement: %w", err)
+		}
+		*dst = stmt
+		w.stmts = append(w.stmts, stmt)
+		return nil
+	}
+
+	steps := []func() error{
+		func() error {
+			return prepare(&w.selectAircraftByRegistration, `
+				SELECT id, aircraft_make_name, aircraft_model_name, aircraft_operator
+				FROM Aircrafts WHERE registration_key = ? ORDER BY id LIMIT 1`)
+		},
+		func() error {
+			return prepare(&w.selectAircraftByFields, `
+				SELECT id, aircraft_make_name, aircraft_model_name, aircraft_operator
+				FROM Aircrafts
+				WHERE registration_number = '' AND aircraft_make_name = ? AND aircraft_model_name = ? AND aircraft_operator = ?
+				ORDER BY id LIMIT 1`)
+		},
+		func() error {
+			return prepareInsert(&w.insertAircraft, `
+				INSERT INTO Aircrafts (registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator, registration_key)
+				VALUES (?, ?, ?, ?, ?)`)
+		},
+		func() error {
+			return prepare(&w.updateAircraft, `
+				UPDATE Aircrafts SET aircraft_make_name = ?, aircraft_model_name = ?, aircraft_operator = ?
+				WHERE id = ?`)
+		},
+		func() error {
+			return prepare(&w.selectLocation, `
+				SELECT id, latitude, longitude, geocode_failed FROM Locations
+				WHERE city_name = ? AND state_name = ? AND country_name = ?
+				ORDER BY id LIMIT 1`)
+		},
+		func() error {
+			return prepareInsert(&w.insertLocation, "INSERT INTO Locations (city_name, state_name, country_name, latitude, longitude, geocode_failed) VALUES (?, ?, ?, ?, ?, ?)")
+		},
+		func() error {
+			return prepare(&w.updateLocation, "UPDATE Locations SET latitude = ?, longitude = ?, geocode_failed = ? WHERE id = ?")
+		},
+		func() error {
+			return prepare(&w.selectAccident, `
+				SELECT a.id, a.updated, a.entry_date, a.remark_text, a.event_type_description, a.flight_number,
+					a.aircraft_missing_flag, a.aircraft_damage_description, a.flight_activity, a.flight_phase,
+					a.far_part, a.fatal_flag, a.location_id, a.aircraft_id
+				FROM Accidents a
+				JOIN Aircrafts ac ON ac.id = a.aircraft_id
+				WHERE ac.registration_number = ? AND a.event_local_date = ? AND a.event_local_time = ? AND a.fsdo_description = ?
+					AND a.source = 'FAA'
+				ORDER BY a.id LIMIT 1`)
+		},
+		func() error {
+			return prepare(&w.selectAccidentBySource, `
+				SELECT id, updated, entry_date, remark_text, event_type_description, flight_number,
+					aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase,
+					far_part, fatal_flag, location_id, aircraft_id
+				FROM Accidents
+				WHERE source = ? AND source_id = ?
+				ORDER BY id LIMIT 1`)
+		},
+		func() error {
+			return prepareInsert(&w.insertAccident, `
+				INSERT INTO Accidents (updated, entry_date, event_local_date, event_local_time, remark_text, event_type_description, fsdo_description, flight_number, aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase, far_part, fatal_flag, location_id, aircraft_id, ingestion_run_id, source, source_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		},
+		func() error {
+			return prepare(&w.updateAccident, `
+				UPDATE Accidents SET updated = ?, entry_date = ?, remark_text = ?, event_type_description = ?, flight_number = ?, aircraft_missing_flag = ?, aircraft_damage_description = ?, flight_activity = ?, flight_phase = ?, far_part = ?, fatal_flag = ?, location_id = ?, aircraft_id = ?, ingestion_run_id = ?
+				WHERE id = ?`)
+		},
+		func() error {
+			return prepare(&w.selectInjuries, "SELECT person_type, injury_severity, count FROM Injuries WHERE accident_id = ?")
+		},
+		func() error {
+			return prepare(&w.deleteInjuries, "DELETE FROM Injuries WHERE accident_id = ?")
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Close closes the prepared statements.
+func (w *recordWriter) Close() {
+	for _, stmt := range w.stmts {
+		stmt.Close()
+	}
+}
+
+// writeRecord upserts the aircraft, location, accident and injuries of a record.
+func (w *recordWriter) writeRecord(ctx context.Context, record *parsedRecord) (recordOutcome, error) {
+	aircraftID, err := w.ensureAircraft(ctx, record.Aircraft, record.Accident.Source != store.SourceNTSB)
+	if err != nil {
+		return 0, err
+	}
+
+	locationID, err := w.ensureLocation(ctx, record.Location)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.upsertAccident(ctx, record.Aircraft.RegistrationNumber, aircraftID, locationID, record.Accident, record.Injuries)
+}
+
+// ensureAircraft upserts the aircraft and returns its ID.
+// Aircraft are identified by their normalized registration, so the FAA's "12345" and the NTSB's "N12345" are the
+// same aircraft, or by all of their fields when the registration is blank.
+// Existing aircraft are only updated when update is set, so that NTSB records, which spell makes and models
+// differently, add the aircraft missing from the FAA data without overwriting the others.
+func (w *recordWriter) ensureAircraft(ctx context.Context, aircraft *models.Aircraft, update bool) (int, error) {
+	key := store.NormalizeRegistration(aircraft.RegistrationNumber)
+	var row *sql.Row
+	if aircraft.RegistrationNumber != "" {
+		row = w.selectAircraftByRegistration.QueryRowContext(ctx, key)
+	} else {
+		row = w.selectAircraftByFields.QueryRowContext(ctx, aircraft.AircraftMakeName, aircraft.AircraftModelName, aircraft.AircraftOperator)
+	}
+
+	var existing models.Aircraft
+	err := row.Scan(&existing.ID, &existing.AircraftMakeName, &existing.AircraftModelName, &existing.AircraftOperator)
+	if err == sql.ErrNoRows {
+		id, err := w.insertAircraft.InsertContext(ctx, aircraft.RegistrationNumber, aircraft.AircraftMakeName, aircraft.AircraftModelName, aircraft.AircraftOperator, key)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting aircraft: %w", err)
+		}
+		return id, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error looking up aircraft: %w", err)
+	}
+
+	if update && (existing.AircraftMakeName != aircraft.AircraftMakeName ||
+		existing.AircraftModelName != aircraft.AircraftModelName ||
+		existing.AircraftOperator != aircraft.AircraftOperator) {
+		_, err = w.updateAircraft.ExecContext(ctx, aircraft.AircraftMakeName, aircraft.AircraftModelName, aircraft.AircraftOperator, existing.ID)
+		if err != nil {
+			return 0, fmt.Errorf("error updating aircraft: %w", err)
+		}
+	}
+
+	return existing.ID, nil
+}
+
+// ensureLocation upserts the location, identified by city, state and country, and returns its ID.
+// Coordinates of an existing location are not cleared when geocoding it again fails.
+func (w *recordWriter) ensureLocation(ctx context.Context, location *models.Location) (int, error) {
+	var existing models.Location
+	err := w.selectLocation.QueryRowContext(ctx, location.CityName, location.StateName, location.CountryName).
+		Scan(&existing.ID, &existing.Latitude, &existing.Longitude, &existing.GeocodeFailed)
+	if err == sql.ErrNoRows {
+		id, err := w.insertLocation.InsertContext(ctx, location.CityName, location.StateName, location.CountryName, location.Latitude, location.Longitude, location.GeocodeFailed)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting location: %w", err)
+		}
+		return id, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error looking up location: %w", err)
+	}
+
+	if location.GeocodeFailed {
+		return existing.ID, nil
+	}
+	if existing.GeocodeFailed || !sameCoordinate(existing.Latitude, location.Latitude) || !sameCoordinate(existing.Longitude, location.Longitude) {
+		if _, err = w.updateLocation.ExecContext(ctx, location.Latitude, location.Longitude, false, existing.ID); err != nil {
+			return 0, fmt.Errorf("error updating location: %w", err)
+		}
+	}
+
+	return existing.ID, nil
+}
+
+// sameCoordinate reports whether two optional coordinates are equal to within the precision of MySQL's
+// single-precision FLOAT columns.
+func sameCoordinate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 1e-4
+}
+
+// upsertAccident inserts the accident with its injuries, or updates the existing accident with the same natural key
+// (aircraft registration, event local date and time, and FSDO) when any of its fields or injuries changed.
+func (w *recordWriter) upsertAccident(ctx context.Context, registration string, aircraftID, locationID int, accident *models.Accident, injuries []*models.Injury) (recordOutcome, error) {
+	accident.AircraftID = aircraftID
+	accident.LocationID = locationID
+
+	existing, err := w.findAccident(ctx, registration, accident)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing == nil {
+		// Dates are written as plain YYYY-MM-DD strings so they compare correctly in every supported database.
+		accidentID, err := w.insertAccident.InsertContext(ctx, accident.Updated, accident.EntryDate.Format(dateLayout), accident.EventLocalDate.Format(dateLayout), accident.EventLocalTime, accident.RemarkText, accident.EventTypeDescription, accident.FSDODescription, accident.FlightNumber, accident.AircraftMissingFlag, accident.AircraftDamageDescription, accident.FlightActivity, accident.FlightPhase, accident.FARPart, accident.FatalFlag, locationID, aircraftID, accident.IngestedBy, accident.Source, accident.SourceID)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting accident: %w", err)
+		}
+		if err := w.insertInjuries(ctx, accidentID, injuries); err != nil {
+			return 0, err
+		}
+		return outcomeInserted, nil
+	}
+
+	existingInjuries, err := w.getInjuries(ctx, existing.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	injuriesChanged := !sameInjuries(existingInjuries, injuries)
+	if sameAccident(existing, accident) && !injuriesChanged {
+		return outcomeUnchanged, nil
+	}
+
+	// The accident row is rewritten even when only its injuries changed, so it points to the run that last updated it.
+	_, err = w.updateAccident.ExecContext(ctx, accident.Updated, accident.EntryDate.Format(dateLayout), accident.RemarkText, accident.EventTypeDescription, accident.FlightNumber, accident.AircraftMissingFlag, accident.AircraftDamageDescription, accident.FlightActivity, accident.FlightPhase, accident.FARPart, accident.FatalFlag, accident.LocationID, accident.AircraftID, accident.IngestedBy, existing.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error updating accident: %w", err)
+	}
+	if injuriesChanged {
+		if _, err := w.deleteInjuries.ExecContext(ctx, existing.ID); err != nil {
+			return 0, fmt.Errorf("error deleting injury data: %w", err)
+		}
+		if err := w.insertInjuries(ctx, existing.ID, injuries); err != nil {
+			return 0, err
+		}
+	}
+
+	return outcomeUpdated, nil
+}
+
+// findAccident looks up an accident by its natural key, returning nil when none exists. NTSB accidents are
+// identified by their event ID and aircraft key instead.
+func (w *recordWriter) findAccident(ctx context.Context, registration string, accident *models.Accident) (*models.Accident, error) {
+	var row *sql.Row
+	if accident.Source == store.SourceNTSB {
+		row = w.selectAccidentBySource.QueryRowContext(ctx, accident.Source, accident.SourceID)
+	} else {
+		row = w.selectAccident.QueryRowContext(ctx, registration, accident.EventLocalDate.Format(dateLayout), accident.EventLocalTime, accident.FSDODescription)
+	}
+
+	var existing models.Accident
+	err := row.Scan(
+		&existing.ID, &existing.Updated, &existing.EntryDate, &existing.RemarkText, &existing.EventTypeDescription,
+		&existing.FlightNumber, &existing.AircraftMissingFlag, &existing.AircraftDamageDescription, &existing.FlightActivity,
+		&existing.FlightPhase, &existing.FARPart, &existing.FatalFlag, &existing.LocationID, &existing.AircraftID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up accident: %w", err)
+	}
+	return &existing, nil
+}
+
+// accidentFields lists the accident columns outside the natural key and how to read each from an accident.
+var accidentFields = []struct {
+	column string
+	value  func(*models.Accident) string
+}{
+	{"updated", func(a *models.Accident) string { return a.Updated }},
+	{"entry_date", func(a *models.Accident) string { return a.EntryDate.Format(dateLayout) }},
+	{"remark_text", func(a *models.Accident) string { return a.RemarkText }},
+	{"event_type_description", func(a *models.Accident) string { return a.EventTypeDescription }},
+	{"flight_number", func(a *models.Accident) string { return a.FlightNumber }},
+	{"aircraft_missing_flag", func(a *models.Accident) string { return a.AircraftMissingFlag }},
+	{"aircraft_damage_description", func(a *models.Accident) string { return a.AircraftDamageDescription }},
+	{"flight_activity", func(a *models.Accident) string { return a.FlightActivity }},
+	{"flight_phase", func(a *models.Accident) string { return a.FlightPhase }},
+	{"far_part", func(a *models.Accident) string { return a.FARPart }},
+	{"fatal_flag", func(a *models.Accident) string { return a.FatalFlag }},
+}
+
+// fieldChange is a field whose value would change.
+type fieldChange struct {
+	Field    string
+	Old, New string
+}
+
+// accidentChanges lists the fields outside the natural key in which the stored accident differs from the parsed one.
+func accidentChanges(existing, accident *models.Accident) []fieldChange {
+	var changes []fieldChange
+	for _, field := range accidentFields {
+		if old, value := field.value(existing), field.value(accident); old != value {
+			changes = append(changes, fieldChange{Field: field.column, Old: old, New: value})
+		}
+	}
+	return changes
+}
+
+// sameAccident reports whether the stored accident matches the parsed one in every field outside the natural key.
+func sameAccident(existing, accident *models.Accident) bool {
+	return len(accidentChanges(existing, accident)) == 0 &&
+		existing.LocationID == accident.LocationID &&
+		existing.AircraftID == accident.AircraftID
+}
+
+// sameInjuries reports whether two sets of injuries have the same counts per person type and severity.
+func sameInjuries(a, b []*models.Injury) bool {
+	counts := func(injuries []*models.Injury) map[string]int {
+		m := make(map[string]int)
+		for _, injury := range injuries {
+			m[injury.PersonType+"/"+injury.InjurySeverity] += injury.Count
+		}
+		return m
+	}
+
+	ca, cb := counts(a), counts(b)
+	if len(ca) != len(cb) {
+		return false
+	}
+	for key, count := range ca {
+		if cb[key] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// getInjuries fetches the stored injuries of an accident.
+func (w *recordWriter) getInjuries(ctx context.Context, accidentID int) ([]*models.Injury, error) {
+	rows, err := w.selectInjuries.QueryContext(ctx, accidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying injuries: %w", err)
+	}
+	defer rows.Close()
+
+	var injuries []*models.Injury
+	for rows.Next() {
+		injury := &models.Injury{AccidentID: accidentID}
+		if err := rows.Scan(&injury.PersonType, &injury.InjurySeverity, &injury.Count); err != nil {
+			return nil, fmt.Errorf("error scanning injury details: %w", err)
+		}
+		injuries = append(injuries, injury)
+	}
+	return injuries, rows.Err()
+}
+
+// insertInjuries inserts the injuries of an accident with a single multi-row INSERT.
+func (w *recordWriter) insertInjuries(ctx context.Context, accidentID int, injuries []*models.Injury) error {
+	if len(injuries) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(injuries))
+	args := make([]interface{}, 0, len(injuries)*4)
+	for _, injury := range injuries {
+		values = append(values, "(?, ?, ?, ?)")
+		args = append(args, accidentID, injury.PersonType, injury.InjurySeverity, injury.Count)
+	}
+
+	query := "INSERT INTO Injuries (accident_id, person_type, injury_severity, count) VALUES " + strings.Join(values, ", ")
+	if _, err := w.tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("error inserting injury data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Writer tests run the importer's upserts against a temporary SQLite database.
+package importer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+	"github.com/computers33333/airaccidentdata/internal/store"
+)
+
+// newTestDB opens a fresh SQLite database with the application schema.
+func newTestDB(t *testing.T) *store.DB {
+	t.Helper()
+
+	db, err := store.OpenDB("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// writeTestRecord writes a parsed record in its own transaction.
+func writeTestRecord(t *testing.T, db *store.DB, aircraft *models.Aircraft, location *models.Location, accident *models.Accident, injuries []*models.Injury) recordOutcome {
+	t.Helper()
+
+	record := &parsedRecord{Aircraft: aircraft, Accident: accident, Location: location, Injuries: injuries}
+	outcomes, err := writeBatch(context.Background(), db, []*parsedRecord{record})
+	if err != nil {
+		t.Fatalf("Expected no error writing record, got %v", err)
+	}
+	return outcomes[0]
+}
+
+// floatPtr returns a pointer to f.
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// countRows returns the number of rows in a table.
+func countRows(t *testing.T, db *store.DB, table string) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("Failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+// TestUpsertAccident tests that re-importing a record is a no-op and that changed records are updated in place.
+func TestUpsertAccident(t *testing.T) {
+	db := newTestDB(t)
+
+	newRecord := func() (*models.Aircraft, *models.Location, *models.Accident, []*models.Injury) {
+		return &models.Aircraft{RegistrationNumber: "N12345", AircraftMakeName: "CESSNA", AircraftModelName: "172", AircraftOperator: "PRIVATE"},
+			&models.Location{CityName: "AUSTIN", StateName: "Texas", CountryName: "United States", Latitude: floatPtr(30.27), Longitude: floatPtr(-97.74)},
+			&models.Accident{
+				Updated:              "No",
+				EntryDate:            time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+				EventLocalDate:       time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				EventLocalTime:       "10:00:00",
+				RemarkText:           "First.",
+				EventTypeDescription: "Accident",
+				FSDODescription:      "FSDO",
+				FlightPhase:          "LANDING",
+				Source:               store.SourceFAA,
+			},
+			[]*models.Injury{{PersonType: "passengers", InjurySeverity: "minor", Count: 2}}
+	}
+
+	aircraft, location, accident, injuries := newRecord()
+	if outcome := writeTestRecord(t, db, aircraft, location, accident, injuries); outcome != outcomeInserted {
+		t.Errorf("Expected first import to insert, got outcome %d", outcome)
+	}
+
+	aircraft, location, accident, injuries = newRecord()
+	if outcome := writeTestRecord(t, db, aircraft, location, accident, injuries); outcome != outcomeUnchanged {
+		t.Errorf("Expected re-import to leave the record unchanged, got outcome %d", outcome)
+	}
+
+	aircraft, location, accident, injuries = newRecord()
+	aircraft.AircraftOperator = "FLIGHT SCHOOL"
+	accident.RemarkText = "First, amended."
+	injuries[0].Count = 3
+	if outcome := writeTestRecord(t, db, aircraft, location, accident, injuries); outcome != outcomeUpdated {
+		t.Errorf("Expected amended record to update, got outcome %d", outcome)
+	}
+
+	for table, want := range map[string]int{"Aircrafts": 1, "Locations": 1, "Accidents": 1, "Injuries": 1} {
+		if got := countRows(t, db, table); got != want {
+			t.Errorf("Expected %d rows in %s, got %d", want, table, got)
+		}
+	}
+
+	var operator, remark string
+	var count int
+	err := db.QueryRow(`
+		SELECT ac.aircraft_operator, a.remark_text, i.count
+		FROM Accidents a JOIN Aircrafts ac ON ac.id = a.aircraft_id JOIN Injuries i ON i.accident_id = a.id`,
+	).Scan(&operator, &remark, &count)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if operator != "FLIGHT SCHOOL" || remark != "First, amended." || count != 3 {
+		t.Errorf("Expected updated operator, remark and injury count, got %q, %q, %d", operator, remark, count)
+	}
+}
+
+// TestBatchWriter_Rollback tests that a failing record rolls back its partial writes without losing the rest of its batch.
+func TestBatchWriter_Rollback(t *testing.T) {
+	db := newTestDB(t)
+
+	// Reject one kind of injury so that its accident is inserted before the record fails.
+	_, err := db.Exec(`CREATE TRIGGER reject_injury BEFORE INSERT ON Injuries WHEN NEW.person_type = 'rejected'
+		BEGIN SELECT RAISE(ABORT, 'rejected injury'); END`)
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	newRecord := func(registration, personType string) *parsedRecord {
+		return &parsedRecord{
+			Aircraft: &models.Aircraft{RegistrationNumber: registration},
+			Location: &models.Location{CityName: "AUSTIN", StateName: "Texas", CountryName: "United States"},
+			Accident: &models.Accident{EventLocalDate: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), EventLocalTime: "10:00:00", Source: store.SourceFAA},
+			Injuries: []*models.Injury{{PersonType: personType, InjurySeverity: "minor", Count: 1}},
+		}
+	}
+
+	writer := newBatchWriter(db, 10, newQuarantine(nil))
+	writer.add(context.Background(), newRecord("N1", "passengers"))
+	writer.add(context.Background(), newRecord("N2", "rejected"))
+	writer.add(context.Background(), newRecord("N3", "passengers"))
+	writer.flush(context.Background())
+
+	expected := Stats{Inserted: 2, Failed: 1}
+	if writer.stats != expected {
+		t.Errorf("Expected stats %+v, got %+v", expected, writer.stats)
+	}
+
+	for table, want := range map[string]int{"Accidents": 2, "Injuries": 2} {
+		if got := countRows(t, db, table); got != want {
+			t.Errorf("Expected %d rows in %s, got %d", want, table, got)
+		}
+	}
+}
@@ -12,15 +12,30 @@ type Aircraft struct {
 	AircraftMakeName   string `json:"aircraft_make_name"`
 	AircraftModelName  string `json:"aircraft_model_name"`
 	AircraftOperator   string `json:"aircraft_operator"`
+
+	// Registration details from the FAA aircraft registry, empty for aircraft not found in it.
+	YearManufactured *int   `json:"year_manufactured,omitempty"`
+	SerialNumber     string `json:"serial_number,omitempty"`
+	EngineType       string `json:"engine_type,omitempty"` // Such as Reciprocating or Turbo-fan
+	NumberOfSeats    *int   `json:"number_of_seats,omitempty"`
+	AircraftCategory string `json:"aircraft_category,omitempty"` // Such as Fixed wing single engine or Rotorcraft
+	OwnerCity        string `json:"owner_city,omitempty"`        // City of the registered owner
+	OwnerState       string `json:"owner_state,omitempty"`       // State code of the registered owner
+
+	// Images is only populated when requested with expand=images.
+	Images []*AircraftImage `json:"images,omitempty"`
 }
 
 type Location struct {
-	ID          int     `json:"id"`
-	CityName    string  `json:"city_name"`
-	StateName   string  `json:"state_name"`
-	CountryName string  `json:"country_name"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
+	ID          int      `json:"id"`
+	CityName    string   `json:"city_name"`
+	StateName   string   `json:"state_name"`
+	CountryName string   `json:"country_name"`
+	Latitude    *float64 `json:"latitude,omitempty"`  // Nil when the place could not be geocoded
+	Longitude   *float64 `json:"longitude,omitempty"` // Nil when the place could not be geocoded
+
+	// GeocodeFailed flags locations imported without coordinates because geocoding failed.
+	GeocodeFailed bool `json:"geocode_failed,omitempty"`
 }
 
 type Accident struct {
@@ -41,6 +56,15 @@ type Accident struct {
 	FatalFlag                 string    `json:"fatal_flag"`
 	LocationID                int       `json:"location_id"`
 	AircraftID                int       `json:"aircraft_id"`
+	IngestedBy                *int      `json:"ingested_by,omitempty"`        // ID of the ingestion run that created or last updated the accident
+	Source                    string    `json:"source" enums:"FAA,NTSB"`      // Dataset the accident was imported from
+	SourceID                  string    `json:"source_id,omitempty"`          // NTSB event ID and aircraft key, empty for FAA accidents
+	LinkedAccidentID          *int      `json:"linked_accident_id,omitempty"` // FAA accident of the same aircraft on the same date, for NTSB accidents
+
+	// Related records, only populated when requested with the expand parameter.
+	Aircraft *Aircraft `json:"aircraft,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	Injuries []*Injury `json:"injuries,omitempty"`
 }
 
 type Injury struct {
@@ -51,6 +75,23 @@ type Injury struct {
 	AccidentID     int    `json:"accident_id"`
 }
 
+// IngestionRun records one import of an FAA CSV file.
+type IngestionRun struct {
+	ID            int        `json:"id"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"` // Nil while the run is in progress
+	SourceURL     string     `json:"source_url"`
+	FileName      string     `json:"file_name"`
+	FileSHA256    string     `json:"file_sha256"`
+	RowsRead      int        `json:"rows_read"`
+	RowsInserted  int        `json:"rows_inserted"`
+	RowsUpdated   int        `json:"rows_updated"`
+	RowsUnchanged int        `json:"rows_unchanged"`
+	RowsFailed    int        `json:"rows_failed"`
+	Status        string     `json:"status" enums:"running,succeeded,failed,interrupted"`
+	Error         string     `json:"error,omitempty"`
+}
+
 type AircraftImage struct {
 	ID         int    `json:"id"`
 	AircraftID int    `json:"aircraft_id"`
@@ -59,17 +100,36 @@ type AircraftImage struct {
 }
 
 type AircraftPaginatedResponse struct {
-	Aircrafts []Aircraft `json:"aircrafts"`
-	Total     int        `json:"total"`
-	Page      int        `json:"page"`
-	Limit     int        `json:"limit"`
+	Aircrafts  []Aircraft `json:"aircrafts"`
+	Total      int        `json:"total"`
+	Page       int        `json:"page"`
+	Limit      int        `json:"limit"`
+	Cursor     string     `json:"cursor,omitempty"`
+	NextCursor string     `json:"next_cursor"`
 }
 
 type AccidentPaginatedResponse struct {
-	Accidents []Accident `json:"accidents"`
-	Total     int        `json:"total"`
-	Page      int        `json:"page"`
-	Limit     int        `json:"limit"`
+	Accidents  []Accident `json:"accidents"`
+	Total      int        `json:"total"`
+	Page       int        `json:"page"`
+	Limit      int        `json:"limit"`
+	Cursor     string     `json:"cursor,omitempty"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+// AircraftRegistrationResponse lists every aircraft recorded under a registration, as datasets may record the same
+// aircraft more than once, with all of their accidents oldest first.
+type AircraftRegistrationResponse struct {
+	RegistrationNumber string     `json:"registration_number"`
+	Aircrafts          []Aircraft `json:"aircrafts"`
+	Accidents          []Accident `json:"accidents"`
+}
+
+type IngestionRunPaginatedResponse struct {
+	Ingestions []IngestionRun `json:"ingestions"`
+	Total      int            `json:"total"`
+	Page       int            `json:"page"`
+	Limit      int            `json:"limit"`
 }
 
 type ImagesForAircraftResponse struct {
@@ -78,7 +138,9 @@ type ImagesForAircraftResponse struct {
 }
 
 type GeoResponse struct {
-	Results []struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+	Results      []struct {
 		Geometry struct {
 			Location struct {
 				Lat float64 `json:"lat"`
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor is malformed or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Pagination selects which part of a result set to return.
+// When Cursor is set the page continues after the row the cursor points at and Page is ignored.
+type Pagination struct {
+	Page   int    // 1-based page number for offset pagination
+	Limit  int    // Maximum number of rows to return
+	Cursor string // Opaque next_cursor value from a previous page
+}
+
+// offset returns the number of rows to skip, which is always 0 when paginating by cursor.
+func (p Pagination) offset() int {
+	if p.Cursor != "" || p.Page < 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
+
+// cursor is the decoded form of an opaque pagination cursor.
+// It records the sort it was issued for and the sort values of the last row returned.
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// String returns the canonical sort expression, including the id tiebreaker.
+func (s Sort) String() string {
+	var parts []string
+	for _, field := range s.withTiebreaker() {
+		if field.Desc {
+			parts = append(parts, "-"+field.Name)
+		} else {
+			parts = append(parts, field.Name)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeCursor builds an opaque cursor from the sort values of the last row of a page.
+func encodeCursor(sortBy Sort, values []interface{}) string {
+	data, _ := json.Marshal(cursor{Sort: sortBy.String(), Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor and checks that it matches the requested sort.
+func decodeCursor(token string, sortBy Sort) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var c cursor
+	if err := decoder.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortBy.String() || len(c.Values) != len(sortBy.withTiebreaker()) {
+		return nil, ErrInvalidCursor
+	}
+
+	for i, value := range c.Values {
+		switch v := value.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			c.Values[i] = n
+		case string:
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return c.Values, nil
+}
+
+// keysetClause builds the condition selecting rows that sort strictly after the cursor values.
+func (s Sort) keysetClause(columns map[string]string, values []interface{}) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+
+	fields := s.withTiebreaker()
+	for i, field := range fields {
+		var terms []string
+		for _, previous := range fields[:i] {
+			terms = append(terms, columns[previous.Name]+" = ?")
+		}
+		args = append(args, values[:i]...)
+
+		if field.Desc {
+			terms = append(terms, columns[field.Name]+" < ?")
+		} else {
+			terms = append(terms, columns[field.Name]+" > ?")
+		}
+		args = append(args, values[i])
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// andWhere appends a condition to a WHERE clause, which may be empty.
+func andWhere(where, condition string) string {
+	if where == "" {
+		return "WHERE " + condition
+	}
+	return where + " AND " + condition
+}
+
+// accidentSortValues returns the values of the sort fields for an accident, in sort order.
+func accidentSortValues(accident *models.Accident, sortBy Sort) []interface{} {
+	var values []interface{}
+	for _, field := range sortBy.withTiebreaker() {
+		switch field.Name {
+		case "id":
+			values = append(values, accident.ID)
+		case "entry_date":
+			values = append(values, accident.EntryDate.Format("2006-01-02"))
+		case "event_local_date":
+			values = append(values, accident.EventLocalDate.Format("2006-01-02"))
+		case "event_type_description":
+			values = append(values, accident.EventTypeDescription)
+		case "aircraft_damage_description":
+			values = append(values, accident.AircraftDamageDescription)
+		case "flight_phase":
+			values = append(values, accident.FlightPhase)
+		case "far_part":
+			values = append(values, accident.FARPart)
+		case "fatal_flag":
+			values = append(values, accident.FatalFlag)
+		}
+	}
+	return values
+}
+
+// aircraftSortValues returns the values of the sort fields for an aircraft, in sort order.
+func aircraftSortValues(aircraft *models.Aircraft, sortBy Sort) []interface{} {
+	var values []interface{}
+	for _, field := range sortBy.withTiebreaker() {
+		switch field.Name {
+		case "id":
+			values = append(values, aircraft.ID)
+		case "registration_number":
+			values = append(values, aircraft.RegistrationNumber)
+		case "aircraft_make_name":
+			values = append(values, aircraft.AircraftMakeName)
+		case "aircraft_model_name":
+			values = append(values, aircraft.AircraftModelName)
+		case "aircraft_operator":
+			values = append(values, aircraft.AircraftOperator)
+		}
+	}
+	return values
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestCursor_RoundTrip tests that a cursor decodes to the values it was encoded with for the same sort.
+func TestCursor_RoundTrip(t *testing.T) {
+	sortBy, err := ParseAccidentSort("-event_local_date")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token := encodeCursor(sortBy, []interface{}{"2023-05-01", 42})
+	values, err := decodeCursor(token, sortBy)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []interface{}{"2023-05-01", int64(42)}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected values %v, got %v", expected, values)
+	}
+}
+
+// TestCursor_Invalid tests that malformed cursors and cursors issued for another sort are rejected.
+func TestCursor_Invalid(t *testing.T) {
+	byDate, _ := ParseAccidentSort("-event_local_date")
+	byID, _ := ParseAccidentSort("")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Not base64", "%%%"},
+		{"Not JSON", "bm90IGpzb24"},
+		{"Different sort", encodeCursor(byID, []interface{}{42})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.token, byDate); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
+
+// TestSort_KeysetClause tests the keyset condition for a mixed-direction sort.
+func TestSort_KeysetClause(t *testing.T) {
+	sortBy, _ := ParseAccidentSort("-event_local_date")
+
+	clause, args := sortBy.keysetClause(accidentSortColumns, []interface{}{"2023-05-01", int64(42)})
+
+	expectedClause := "((a.event_local_date < ?) OR (a.event_local_date = ? AND a.id > ?))"
+	if clause != expectedClause {
+		t.Errorf("Expected clause %q, got %q", expectedClause, clause)
+	}
+	expectedArgs := []interface{}{"2023-05-01", "2023-05-01", int64(42)}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Expected args %v, got %v", expectedArgs, args)
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql" // Blank identifier imports MySQL driver to initialize and register it.
+	_ "github.com/jackc/pgx/v5/stdlib" // Blank identifier imports the pgx PostgreSQL driver to initialize and register it.
+	_ "modernc.org/sqlite"             // Blank identifier imports the pure-Go SQLite driver to initialize and register it.
+)
+
+// Dialect identifies the SQL database a store is backed by.
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// sqliteScheme prefixes data source names that point at a SQLite database file, e.g. sqlite://airaccidentdata.db.
+const sqliteScheme = "sqlite://"
+
+//go:embed schema_sqlite.sql
+var sqliteSchema string
+
+//go:embed schema_postgres.sql
+var postgresSchema string
+
+// DB wraps a database connection pool with its dialect.
+// Queries are written with ? placeholders and rewritten for dialects that number them.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// OpenDB opens and pings the database described by the data source name.
+// Names starting with sqlite:// open a SQLite file and postgres:// or postgresql:// URLs open PostgreSQL,
+// creating the schema and migrating older tables if needed; anything else is a MySQL DSN, whose schema is
+// applied and migrated by the container entrypoint.
+func OpenDB(dataSourceName string) (*DB, error) {
+	dialect, driverName, dsn := parseDataSourceName(dataSourceName)
+
+	sqlDB, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err = sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	switch dialect {
+	case DialectSQLite:
+		// SQLite allows a single writer, so serialize access through one connection.
+		sqlDB.SetMaxOpenConns(1)
+		if _, err := sqlDB.Exec(sqliteSchema); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to apply SQLite schema: %w", err)
+		}
+	case DialectPostgres:
+		if _, err := sqlDB.Exec(postgresSchema); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to apply PostgreSQL schema: %w", err)
+		}
+	}
+
+	db := &DB{DB: sqlDB, Dialect: dialect}
+	if dialect != DialectMySQL {
+		if err := Migrate(context.Background(), db); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+	return db, nil
+}
+
+// parseDataSourceName returns the dialect, database/sql driver name and driver DSN for a data source name.
+func parseDataSourceName(dataSourceName string) (Dialect, string, string) {
+	switch {
+	case strings.HasPrefix(dataSourceName, sqliteScheme):
+		path := strings.TrimPrefix(dataSourceName, sqliteScheme)
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		return DialectSQLite, "sqlite", path + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	case strings.HasPrefix(dataSourceName, "postgres://"), strings.HasPrefix(dataSourceName, "postgresql://"):
+		return DialectPostgres, "pgx", dataSourceName
+	default:
+		return DialectMySQL, "mysql", dataSourceName
+	}
+}
+
+// Rebind rewrites ? placeholders into the dialect's placeholder syntax.
+// Question marks inside single-quoted string literals are left alone.
+func (db *DB) Rebind(query string) string {
+	return db.Dialect.Rebind(query)
+}
+
+// Rebind rewrites ? placeholders into the dialect's placeholder syntax.
+// Question marks inside single-quoted string literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	inString := false
+	n := 0
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// returningID reports whether the dialect reads generated ids back with RETURNING instead of LastInsertId.
+func (d Dialect) returningID() bool {
+	return d == DialectPostgres
+}
+
+// insertQuery appends RETURNING id to an INSERT statement for dialects without LastInsertId.
+func (d Dialect) insertQuery(query string) string {
+	if !d.returningID() {
+		return query
+	}
+	return strings.TrimRight(strings.TrimSpace(query), ";") + " RETURNING id"
+}
+
+// Query executes a query that returns rows.
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Rebind(query), args...)
+}
+
+// QueryContext executes a query that returns rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.Rebind(query), args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row.
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Rebind(query), args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one row.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Rebind(query), args...)
+}
+
+// Exec executes a query without returning any rows.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Rebind(query), args...)
+}
+
+// ExecContext executes a query without returning any rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Rebind(query), args...)
+}
+
+// InsertContext executes an INSERT statement and returns the generated id of the new row.
+// PostgreSQL has no LastInsertId, so the id is read back with RETURNING instead.
+func (db *DB) InsertContext(ctx context.Context, query string, args ...interface{}) (int, error) {
+	if db.Dialect.returningID() {
+		var id int
+		err := db.QueryRowContext(ctx, db.Dialect.insertQuery(query), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// BeginTx starts a transaction whose queries are rewritten for the database's dialect.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: db.Dialect}, nil
+}
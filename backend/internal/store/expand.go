@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+)
+
+// Expand selects the related records to embed in accident responses.
+type Expand struct {
+	Aircraft bool
+	Location bool
+	Injuries bool
+	Images   bool // Embedded in the aircraft, so it implies Aircraft
+}
+
+// ParseExpand parses a comma separated list such as "aircraft,location,injuries,images".
+func ParseExpand(expr string) (Expand, error) {
+	var expand Expand
+	for _, part := range strings.Split(expr, ",") {
+		switch strings.TrimSpace(part) {
+		case "":
+		case "aircraft":
+			expand.Aircraft = true
+		case "location":
+			expand.Location = true
+		case "injuries":
+			expand.Injuries = true
+		case "images":
+			expand.Aircraft = true
+			expand.Images = true
+		default:
+			return expand, fmt.Errorf("unknown expand field %q", strings.TrimSpace(part))
+		}
+	}
+	return expand, nil
+}
+
+// Any reports whether any related record is selected.
+func (e Expand) Any() bool {
+	return e.Aircraft || e.Location || e.Injuries || e.Images
+}
+
+// ExpandAccidents embeds the selected related records into the accidents,
+// loading each kind of record with a single query for all accidents.
+func (s *Store) ExpandAccidents(ctx context.Context, accidents []*models.Accident, expand Expand) error {
+	return expandAccidents(ctx, s, accidents, expand)
+}
+
+// expandAccidents embeds the selected related records into the accidents using the store's batch lookups.
+func expandAccidents(ctx context.Context, s StoreInterface, accidents []*models.Accident, expand Expand) error {
+	if len(accidents) == 0 || !expand.Any() {
+		return nil
+	}
+
+	var accidentIDs, aircraftIDs, locationIDs []int
+	for _, accident := range accidents {
+		accidentIDs = append(accidentIDs, accident.ID)
+		aircraftIDs = append(aircraftIDs, accident.AircraftID)
+		locationIDs = append(locationIDs, accident.LocationID)
+	}
+
+	if expand.Aircraft {
+		aircrafts, err := s.GetAircraftsByIds(ctx, aircraftIDs)
+		if err != nil {
+			return err
+		}
+
+		if expand.Images {
+			images, err := s.GetImagesByAircraftIds(ctx, aircraftIDs)
+			if err != nil {
+				return err
+			}
+			for id, aircraft := range aircrafts {
+				aircraft.Images = images[id]
+			}
+		}
+
+		for _, accident := range accidents {
+			accident.Aircraft = aircrafts[accident.AircraftID]
+		}
+	}
+
+	if expand.Location {
+		locations, err := s.GetLocationsByIds(ctx, locationIDs)
+		if err != nil {
+			return err
+		}
+		for _, accident := range accidents {
+			accident.Location = locations[accident.LocationID]
+		}
+	}
+
+	if expand.Injuries {
+		injuries, err := s.GetInjuriesByAccidentIds(ctx, accidentIDs)
+		if err != nil {
+			return err
+		}
+		for _, accident := range accidents {
+			accident.Injuries = injuries[accident.ID]
+		}
+	}
+
+	return nil
+}
+
+// GetAircraftsByIds fetches the aircraft with the given IDs, keyed by ID.
+func (s *Store) GetAircraftsByIds(ctx context.Context, ids []int) (map[int]*models.Aircraft, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	placeholders, args := inClause(ids)
+	query := `SELECT id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator FROM Aircrafts WHERE id IN (` + placeholders + `)`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching aircrafts: %w", err)
+	}
+	defer rows.Close()
+
+	aircrafts := make(map[int]*models.Aircraft)
+	for rows.Next() {
+		var aircraft models.Aircraft
+		if err := rows.Scan(
+			&aircraft.ID,
+			&aircraft.RegistrationNumber,
+			&aircraft.AircraftMakeName,
+			&aircraft.AircraftModelName,
+			&aircraft.AircraftOperator,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning aircraft row: %w", err)
+		}
+		aircrafts[aircraft.ID] = &aircraft
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over aircrafts: %w", err)
+	}
+
+	return aircrafts, nil
+}
+
+// GetLocationsByIds fetches the locations with the given IDs, keyed by ID.
+func (s *Store) GetLocationsByIds(ctx context.Context, ids []int) (map[int]*models.Location, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	placeholders, args := inClause(ids)
+	query := `SELECT id, city_name, state_name, country_name, latitude, longitude, geocode_failed FROM Locations WHERE id IN (` + placeholders + `)`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := make(map[int]*models.Location)
+	for rows.Next() {
+		var location models.Location
+		if err := rows.Scan(&location.ID, &location.CityName, &location.StateName, &location.CountryName, &location.Latitude, &location.Longitude, &location.GeocodeFailed); err != nil {
+			return nil, fmt.Errorf("error scanning location row: %w", err)
+		}
+		locations[location.ID] = &location
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over locations: %w", err)
+	}
+
+	return locations, nil
+}
+
+// GetInjuriesByAccidentIds fetches the injuries of the given accidents, grouped by accident ID.
+func (s *Store) GetInjuriesByAccidentIds(ctx context.Context, accidentIDs []int) (map[int][]*models.Injury, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	placeholders, args := inClause(accidentIDs)
+	query := `SELECT id, person_type, injury_severity, count, accident_id FROM Injuries WHERE accident_id IN (` + placeholders + `) ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying injuries: %w", err)
+	}
+	defer rows.Close()
+
+	injuries := make(map[int][]*models.Injury)
+	for rows.Next() {
+		var injury models.Injury
+		if err := rows.Scan(&injury.ID, &injury.PersonType, &injury.InjurySeverity, &injury.Count, &injury.AccidentID); err != nil {
+			return nil, fmt.Errorf("error scanning injury details: %w", err)
+		}
+		injuries[injury.AccidentID] = append(injuries[injury.AccidentID], &injury)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over injuries: %w", err)
+	}
+
+	return injuries, nil
+}
+
+// GetImagesByAircraftIds fetches the images of the given aircraft, grouped by aircraft ID.
+func (s *Store) GetImagesByAircraftIds(ctx context.Context, aircraftIDs []int) (map[int][]*models.AircraftImage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	placeholders, args := inClause(aircraftIDs)
+	query := `SELECT id, aircraft_id, image_url, COALESCE(s3_url, '') AS s3_url FROM AircraftImages WHERE aircraft_id IN (` + placeholders + `) ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching aircraft images: %w", err)
+	}
+	defer rows.Close()
+
+	images := make(map[int][]*models.AircraftImage)
+	for rows.Next() {
+		var image models.AircraftImage
+		if err := rows.Scan(&image.ID, &image.AircraftID, &image.ImageURL, &image.S3URL); err != nil {
+			return nil, fmt.Errorf("error scanning image details: %w", err)
+		}
+		images[image.AircraftID] = append(images[image.AircraftID], &image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iteration error: %w", err)
+	}
+
+	return images, nil
+}
+
+// inClause returns the placeholders and arguments for an IN list of the distinct IDs.
+func inClause(ids []int) (string, []interface{}) {
+	seen := make(map[int]bool)
+	var placeholders []string
+	var args []interface{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	return strings.Join(placeholders, ", "), args
+}
@@ -0,0 +1,41 @@
+package store
+
+import "testing"
+
+// TestParseExpand tests parsing the expand parameter, including images implying the aircraft.
+func TestParseExpand(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		wantErr  bool
+		expected Expand
+	}{
+		{"Empty", "", false, Expand{}},
+		{"Location and injuries", "location, injuries", false, Expand{Location: true, Injuries: true}},
+		{"Images imply aircraft", "images", false, Expand{Aircraft: true, Images: true}},
+		{"Unknown field", "aircraft,narrative", true, Expand{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expand, err := ParseExpand(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExpand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && expand != tt.expected {
+				t.Errorf("ParseExpand() = %+v, want %+v", expand, tt.expected)
+			}
+		})
+	}
+}
+
+// TestInClause tests that IN list placeholders are generated once per distinct ID.
+func TestInClause(t *testing.T) {
+	placeholders, args := inClause([]int{3, 1, 3, 2})
+	if placeholders != "?, ?, ?" {
+		t.Errorf("Expected placeholders %q, got %q", "?, ?, ?", placeholders)
+	}
+	if len(args) != 3 {
+		t.Errorf("Expected 3 args, got %d", len(args))
+	}
+}
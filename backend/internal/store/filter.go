@@ -0,0 +1,90 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// Datasets accidents are imported from.
+const (
+	SourceFAA  = "FAA"  // FAA Accident and Incident Data System preliminary reports
+	SourceNTSB = "NTSB" // NTSB aviation accident investigations
+)
+
+// AccidentFilter narrows the accidents returned by GetAccidents.
+// Zero-valued fields are ignored; all set fields are combined with AND.
+type AccidentFilter struct {
+	EventDateFrom             *time.Time // Inclusive lower bound on event_local_date
+	EventDateTo               *time.Time // Inclusive upper bound on event_local_date
+	FatalFlag                 string     // "Yes" or "No"
+	FlightPhase               string
+	FARPart                   string
+	EventTypeDescription      string
+	AircraftDamageDescription string
+	StateName                 string // Matched against the accident's location
+	AircraftMakeName          string // Matched against the accident's aircraft
+	AircraftModelName         string // Matched against the accident's aircraft
+	Source                    string // SourceFAA or SourceNTSB
+	Registration              string // Normalized with NormalizeRegistration, matched however the aircraft's is written
+}
+
+// whereClause builds the SQL WHERE clause and its arguments for the filter.
+// Column references assume Accidents is aliased as a, Aircrafts as ac and Locations as l.
+func (f AccidentFilter) whereClause() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if f.EventDateFrom != nil {
+		add("a.event_local_date >= ?", f.EventDateFrom.Format("2006-01-02"))
+	}
+	if f.EventDateTo != nil {
+		add("a.event_local_date <= ?", f.EventDateTo.Format("2006-01-02"))
+	}
+	switch f.FatalFlag {
+	case "":
+	case "No":
+		// Non-fatal accidents are recorded with an empty flag rather than "No".
+		add("COALESCE(a.fatal_flag, '') <> ?", "Yes")
+	default:
+		add("a.fatal_flag = ?", f.FatalFlag)
+	}
+	if f.FlightPhase != "" {
+		add("a.flight_phase = ?", f.FlightPhase)
+	}
+	if f.FARPart != "" {
+		add("a.far_part = ?", f.FARPart)
+	}
+	if f.EventTypeDescription != "" {
+		add("a.event_type_description = ?", f.EventTypeDescription)
+	}
+	if f.AircraftDamageDescription != "" {
+		add("a.aircraft_damage_description = ?", f.AircraftDamageDescription)
+	}
+	if f.StateName != "" {
+		add("l.state_name = ?", f.StateName)
+	}
+	if f.AircraftMakeName != "" {
+		add("ac.aircraft_make_name = ?", f.AircraftMakeName)
+	}
+	if f.AircraftModelName != "" {
+		add("ac.aircraft_model_name = ?", f.AircraftModelName)
+	}
+	if f.Source != "" {
+		add("a.source = ?", f.Source)
+	}
+	if f.Registration != "" {
+		condition, registrationArgs := registrationCondition(f.Registration)
+		conditions = append(conditions, condition)
+		args = append(args, registrationArgs...)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestAccidentFilter_WhereClause tests that set filter fields are combined with AND in a stable order.
+func TestAccidentFilter_WhereClause(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filter    AccidentFilter
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{"Empty filter", AccidentFilter{}, "", nil},
+		{
+			"Date range and state",
+			AccidentFilter{EventDateFrom: &from, EventDateTo: &to, StateName: "Texas"},
+			"WHERE a.event_local_date >= ? AND a.event_local_date <= ? AND l.state_name = ?",
+			[]interface{}{"2023-01-01", "2023-12-31", "Texas"},
+		},
+		{
+			"Non-fatal with make and model",
+			AccidentFilter{FatalFlag: "No", AircraftMakeName: "CESSNA", AircraftModelName: "172"},
+			"WHERE COALESCE(a.fatal_flag, '') <> ? AND ac.aircraft_make_name = ? AND ac.aircraft_model_name = ?",
+			[]interface{}{"Yes", "CESSNA", "172"},
+		},
+		{
+			"Source",
+			AccidentFilter{FatalFlag: "Yes", Source: SourceNTSB},
+			"WHERE a.fatal_flag = ? AND a.source = ?",
+			[]interface{}{"Yes", "NTSB"},
+		},
+		{
+			"Registration",
+			AccidentFilter{Registration: "N12345"},
+			"WHERE ac.registration_key = ?",
+			[]interface{}{"N12345"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.filter.whereClause()
+			if where != tt.wantWhere {
+				t.Errorf("whereClause() where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("whereClause() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
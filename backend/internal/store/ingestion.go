@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+)
+
+// Statuses of an ingestion run.
+const (
+	IngestionRunning     = "running"
+	IngestionSucceeded   = "succeeded"
+	IngestionFailed      = "failed"
+	IngestionInterrupted = "interrupted"
+)
+
+// selectIngestionRuns selects the columns read by scanIngestionRun.
+const selectIngestionRuns = `
+	SELECT id, started_at, finished_at, COALESCE(source_url, ''), COALESCE(file_name, ''), COALESCE(file_sha256, ''),
+		rows_read, rows_inserted, rows_updated, rows_unchanged, rows_failed, status, COALESCE(error_message, '')
+	FROM IngestionRuns`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanIngestionRun scans a row selected by selectIngestionRuns.
+func scanIngestionRun(row rowScanner) (*models.IngestionRun, error) {
+	var run models.IngestionRun
+	err := row.Scan(
+		&run.ID, &run.StartedAt, &run.FinishedAt, &run.SourceURL, &run.FileName, &run.FileSHA256,
+		&run.RowsRead, &run.RowsInserted, &run.RowsUpdated, &run.RowsUnchanged, &run.RowsFailed,
+		&run.Status, &run.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// StartIngestionRun records the start of an import, setting the run's ID, start time and status.
+func StartIngestionRun(ctx context.Context, db *DB, run *models.IngestionRun) error {
+	run.StartedAt = time.Now().UTC().Truncate(time.Second)
+	run.Status = IngestionRunning
+
+	id, err := db.InsertContext(ctx, `
+		INSERT INTO IngestionRuns (started_at, source_url, file_name, file_sha256, status)
+		VALUES (?, ?, ?, ?, ?)`,
+		run.StartedAt, run.SourceURL, run.FileName, run.FileSHA256, run.Status)
+	if err != nil {
+		return fmt.Errorf("error recording ingestion run: %w", err)
+	}
+	run.ID = id
+	return nil
+}
+
+// FinishIngestionRun records the end of an import with its row counts, status and error, and the file's SHA-256,
+// which is only known at the end for streamed input.
+func FinishIngestionRun(ctx context.Context, db *DB, run *models.IngestionRun) error {
+	finishedAt := time.Now().UTC().Truncate(time.Second)
+	run.FinishedAt = &finishedAt
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE IngestionRuns
+		SET finished_at = ?, rows_read = ?, rows_inserted = ?, rows_updated = ?, rows_unchanged = ?, rows_failed = ?,
+			status = ?, error_message = ?, file_sha256 = ?
+		WHERE id = ?`,
+		finishedAt, run.RowsRead, run.RowsInserted, run.RowsUpdated, run.RowsUnchanged, run.RowsFailed,
+		run.Status, run.Error, run.FileSHA256, run.ID)
+	if err != nil {
+		return fmt.Errorf("error recording ingestion run: %w", err)
+	}
+	return nil
+}
+
+// LastIngestionRun fetches the most recent run, returning nil when there is none.
+func LastIngestionRun(ctx context.Context, db *DB) (*models.IngestionRun, error) {
+	return lastIngestionRun(ctx, db, "")
+}
+
+// SucceededIngestionRunBySHA256 fetches the most recent successful run that imported a file with the SHA-256,
+// returning nil when there is none.
+func SucceededIngestionRunBySHA256(ctx context.Context, db *DB, sha256 string) (*models.IngestionRun, error) {
+	return lastIngestionRun(ctx, db, " WHERE status = ? AND file_sha256 = ?", IngestionSucceeded, sha256)
+}
+
+// lastIngestionRun fetches the most recent run matching the where clause, or any run if it is empty.
+func lastIngestionRun(ctx context.Context, db *DB, where string, args ...interface{}) (*models.IngestionRun, error) {
+	query := selectIngestionRuns + where + " ORDER BY id DESC LIMIT 1"
+
+	run, err := scanIngestionRun(db.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching last ingestion run: %w", err)
+	}
+	return run, nil
+}
+
+// GetIngestionRuns fetches a page of ingestion runs, most recent first, and the total number of runs.
+func (s *Store) GetIngestionRuns(ctx context.Context, page Pagination) ([]*models.IngestionRun, int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := selectIngestionRuns + `
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, page.Limit, page.offset())
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching ingestion runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.IngestionRun
+	for rows.Next() {
+		run, err := scanIngestionRun(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning ingestion run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating over ingestion runs: %w", err)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM IngestionRuns`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting ingestion runs: %w", err)
+	}
+
+	return runs, total, nil
+}
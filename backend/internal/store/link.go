@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// LinkNTSBAccidents links each NTSB accident that is not linked yet to the FAA accident of an aircraft with the
+// same registration on the same event date, returning the number of accidents linked. Registrations are compared by
+// their registration_key, as the FAA data keeps them as written, with or without the N prefix. Both importers call
+// it, so accidents are linked whichever dataset is imported first.
+func LinkNTSBAccidents(ctx context.Context, db *DB) (int, error) {
+	// The pairs are read before updating, as MySQL cannot update a table it reads in a subquery and SQLite
+	// shares a single connection.
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.id, MIN(f.id)
+		FROM Accidents n
+		JOIN Aircrafts na ON na.id = n.aircraft_id
+		JOIN Aircrafts fa ON fa.registration_key = na.registration_key
+		JOIN Accidents f ON f.aircraft_id = fa.id AND f.event_local_date = n.event_local_date
+		WHERE n.source = ? AND n.linked_accident_id IS NULL AND na.registration_key <> '' AND f.source = ?
+		GROUP BY n.id`, SourceNTSB, SourceFAA)
+	if err != nil {
+		return 0, fmt.Errorf("error finding accidents to link: %w", err)
+	}
+	links := make(map[int]int)
+	for rows.Next() {
+		var ntsbID, faaID int
+		if err := rows.Scan(&ntsbID, &faaID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning accidents to link: %w", err)
+		}
+		links[ntsbID] = faaID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error finding accidents to link: %w", err)
+	}
+	if len(links) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for ntsbID, faaID := range links {
+		if _, err := tx.ExecContext(ctx, "UPDATE Accidents SET linked_accident_id = ? WHERE id = ?", faaID, ntsbID); err != nil {
+			return 0, fmt.Errorf("error linking accident %d: %w", ntsbID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return len(links), nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+)
+
+// AdvisoryLock is a named lock shared by every process using the database, such as the lock that lets a single
+// ingestion daemon import at a time. MySQL and PostgreSQL locks belong to a database session, so the lock keeps
+// the connection that took it until it is released.
+type AdvisoryLock struct {
+	db   *DB
+	conn *sql.Conn // Session holding the lock, nil for SQLite
+	name string
+}
+
+// sqliteLocks holds the SQLite locks taken by this process. A SQLite database is a local file opened through a
+// single connection, which cannot be set aside for the lock, so SQLite locks only exclude this process's callers.
+var sqliteLocks sync.Map
+
+// TryAdvisoryLock takes the named lock without waiting, returning nil when it is held elsewhere.
+func TryAdvisoryLock(ctx context.Context, db *DB, name string) (*AdvisoryLock, error) {
+	if db.Dialect == DialectSQLite {
+		if _, held := sqliteLocks.LoadOrStore(name, struct{}{}); held {
+			return nil, nil
+		}
+		return &AdvisoryLock{db: db, name: name}, nil
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock %s: %w", name, err)
+	}
+
+	// GET_LOCK returns 1 when the lock is taken and 0 when another session holds it.
+	query := "SELECT COALESCE(GET_LOCK(?, 0), 0) = 1"
+	if db.Dialect == DialectPostgres {
+		query = "SELECT pg_try_advisory_lock(hashtext(?))"
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, db.Rebind(query), name).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error acquiring lock %s: %w", name, err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &AdvisoryLock{db: db, conn: conn, name: name}, nil
+}
+
+// Release releases the lock. Closing the session also releases it, so the connection is returned to the pool
+// only once the lock is released.
+func (l *AdvisoryLock) Release() error {
+	if l.conn == nil {
+		sqliteLocks.Delete(l.name)
+		return nil
+	}
+
+	query := "SELECT RELEASE_LOCK(?)"
+	if l.db.Dialect == DialectPostgres {
+		query = "SELECT pg_advisory_unlock(hashtext(?))"
+	}
+	_, err := l.conn.ExecContext(context.Background(), l.db.Rebind(query), l.name)
+	if err != nil {
+		// The session may still hold the lock; discard it so the database releases the lock.
+		l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		l.conn.Close()
+		return fmt.Errorf("error releasing lock %s: %w", l.name, err)
+	}
+	return l.conn.Close()
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// migration adds a column to a table created by an older schema. The schema files only create missing tables, so
+// columns added since are added by a migration when they are missing. Statements are per dialect and run in order.
+type migration struct {
+	table      string
+	column     string
+	statements map[Dialect][]string
+}
+
+// migrations lists the columns added to the schema since tables were first created, oldest first.
+var migrations = []migration{
+	{
+		table:  "Locations",
+		column: "geocode_failed",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Locations ADD COLUMN geocode_failed BOOLEAN NOT NULL DEFAULT FALSE"},
+			DialectSQLite:   {"ALTER TABLE Locations ADD COLUMN geocode_failed BOOLEAN NOT NULL DEFAULT FALSE"},
+			DialectPostgres: {"ALTER TABLE Locations ADD COLUMN IF NOT EXISTS geocode_failed BOOLEAN NOT NULL DEFAULT FALSE"},
+		},
+	},
+	{
+		table:  "Accidents",
+		column: "ingestion_run_id",
+		statements: map[Dialect][]string{
+			DialectMySQL: {"ALTER TABLE Accidents ADD COLUMN ingestion_run_id INT, " +
+				"ADD FOREIGN KEY (ingestion_run_id) REFERENCES IngestionRuns(id)"},
+			DialectSQLite:   {"ALTER TABLE Accidents ADD COLUMN ingestion_run_id INTEGER REFERENCES IngestionRuns(id)"},
+			DialectPostgres: {"ALTER TABLE Accidents ADD COLUMN IF NOT EXISTS ingestion_run_id INT REFERENCES IngestionRuns(id)"},
+		},
+	},
+	{
+		// Accidents imported before NTSB data were all from the FAA.
+		table:  "Accidents",
+		column: "source",
+		statements: map[Dialect][]string{
+			DialectMySQL: {
+				"ALTER TABLE Accidents ADD COLUMN source VARCHAR(10) NOT NULL DEFAULT 'FAA'",
+				"UPDATE Accidents SET source = 'FAA' WHERE source IS NULL OR source = ''",
+			},
+			DialectSQLite: {
+				"ALTER TABLE Accidents ADD COLUMN source TEXT COLLATE NOCASE NOT NULL DEFAULT 'FAA'",
+				"UPDATE Accidents SET source = 'FAA' WHERE source IS NULL OR source = ''",
+			},
+			DialectPostgres: {
+				"ALTER TABLE Accidents ADD COLUMN IF NOT EXISTS source CITEXT NOT NULL DEFAULT 'FAA'",
+				"UPDATE Accidents SET source = 'FAA' WHERE source IS NULL OR source = ''",
+			},
+		},
+	},
+	{
+		table:  "Accidents",
+		column: "source_id",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Accidents ADD COLUMN source_id VARCHAR(64), ADD INDEX idx_accidents_source (source, source_id)"},
+			DialectSQLite:   {"ALTER TABLE Accidents ADD COLUMN source_id TEXT"},
+			DialectPostgres: {"ALTER TABLE Accidents ADD COLUMN IF NOT EXISTS source_id VARCHAR(64)"},
+		},
+	},
+	{
+		table:  "Accidents",
+		column: "linked_accident_id",
+		statements: map[Dialect][]string{
+			DialectMySQL: {"ALTER TABLE Accidents ADD COLUMN linked_accident_id INT, " +
+				"ADD FOREIGN KEY (linked_accident_id) REFERENCES Accidents(id)"},
+			DialectSQLite:   {"ALTER TABLE Accidents ADD COLUMN linked_accident_id INTEGER REFERENCES Accidents(id)"},
+			DialectPostgres: {"ALTER TABLE Accidents ADD COLUMN IF NOT EXISTS linked_accident_id INT REFERENCES Accidents(id)"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "year_manufactured",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN year_manufactured SMALLINT"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN year_manufactured INTEGER"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS year_manufactured SMALLINT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "serial_number",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN serial_number VARCHAR(30)"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN serial_number TEXT COLLATE NOCASE"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS serial_number CITEXT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "engine_type",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN engine_type VARCHAR(30)"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN engine_type TEXT COLLATE NOCASE"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS engine_type CITEXT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "number_of_seats",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN number_of_seats SMALLINT"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN number_of_seats INTEGER"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS number_of_seats SMALLINT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "aircraft_category",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN aircraft_category VARCHAR(30)"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN aircraft_category TEXT COLLATE NOCASE"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS aircraft_category CITEXT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "owner_city",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN owner_city VARCHAR(50)"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN owner_city TEXT COLLATE NOCASE"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS owner_city CITEXT"},
+		},
+	},
+	{
+		table:  "Aircrafts",
+		column: "owner_state",
+		statements: map[Dialect][]string{
+			DialectMySQL:    {"ALTER TABLE Aircrafts ADD COLUMN owner_state VARCHAR(2)"},
+			DialectSQLite:   {"ALTER TABLE Aircrafts ADD COLUMN owner_state TEXT COLLATE NOCASE"},
+			DialectPostgres: {"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS owner_state CITEXT"},
+		},
+	},
+	{
+		// Aircraft stored before the column get the normalized form of their registration.
+		table:  "Aircrafts",
+		column: "registration_key",
+		statements: map[Dialect][]string{
+			DialectMySQL: {
+				"ALTER TABLE Aircrafts ADD COLUMN registration_key VARCHAR(255) NOT NULL DEFAULT '', " +
+					"ADD INDEX idx_aircrafts_registration_key (registration_key)",
+				"UPDATE Aircrafts SET registration_key = " + registrationKeySQL(DialectMySQL, "registration_number"),
+			},
+			DialectSQLite: {
+				"ALTER TABLE Aircrafts ADD COLUMN registration_key TEXT NOT NULL DEFAULT ''",
+				"UPDATE Aircrafts SET registration_key = " + registrationKeySQL(DialectSQLite, "registration_number"),
+			},
+			DialectPostgres: {
+				"ALTER TABLE Aircrafts ADD COLUMN IF NOT EXISTS registration_key VARCHAR(255) NOT NULL DEFAULT ''",
+				"UPDATE Aircrafts SET registration_key = " + registrationKeySQL(DialectPostgres, "registration_number"),
+			},
+		},
+	},
+}
+
+// migratedIndexes lists the indexes on migrated columns, which the schema cannot create on older tables before they
+// are migrated. They are created once the columns exist; MySQL declares them in its schema and migrations instead.
+var migratedIndexes = map[Dialect][]string{
+	DialectSQLite: {
+		"CREATE INDEX IF NOT EXISTS idx_accidents_source ON Accidents (source, source_id)",
+		"CREATE INDEX IF NOT EXISTS idx_aircrafts_registration_key ON Aircrafts (registration_key)",
+	},
+	DialectPostgres: {
+		"CREATE INDEX IF NOT EXISTS idx_accidents_source ON Accidents (source, source_id)",
+		"CREATE INDEX IF NOT EXISTS idx_aircrafts_registration_key ON Aircrafts (registration_key)",
+	},
+}
+
+// Migrate adds the columns missing from tables created by an older schema, and the indexes on them. It is
+// idempotent: migrations whose column exists are skipped, so it runs after the schema is applied on every start.
+func Migrate(ctx context.Context, db *DB) error {
+	for _, m := range migrations {
+		exists, err := columnExists(ctx, db, m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		for _, statement := range m.statements[db.Dialect] {
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("error adding %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+	for _, statement := range migratedIndexes[db.Dialect] {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error creating index: %w", err)
+		}
+	}
+	return nil
+}
+
+// columnExists reports whether the table of the current database has the column.
+func columnExists(ctx context.Context, db *DB, table, column string) (bool, error) {
+	var query string
+	switch db.Dialect {
+	case DialectSQLite:
+		query = "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ? COLLATE NOCASE"
+	case DialectPostgres:
+		// Unquoted identifiers are stored in lower case.
+		query = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = LOWER(?) AND column_name = LOWER(?)`
+	default:
+		query = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	}
+
+	var n int
+	if err := db.QueryRowContext(ctx, query, table, column).Scan(&n); err != nil {
+		return false, fmt.Errorf("error checking for %s.%s: %w", table, column, err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// oldSchema creates the tables as they were before the migrated columns were added.
+const oldSchema = `
+CREATE TABLE Aircrafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    registration_number TEXT,
+    aircraft_make_name TEXT,
+    aircraft_model_name TEXT,
+    aircraft_operator TEXT
+);
+INSERT INTO Aircrafts (id, registration_number) VALUES (1, 'N12345'), (2, ' 12345'), (3, 'n-777ab'), (4, 'C-GABC'), (5, NULL);
+CREATE TABLE Locations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    city_name TEXT,
+    state_name TEXT,
+    country_name TEXT,
+    latitude REAL,
+    longitude REAL
+);
+INSERT INTO Locations (id, city_name, state_name, country_name, latitude, longitude) VALUES
+    (1, 'AUSTIN', 'Texas', 'United States', 30.27, -97.74);
+CREATE TABLE Accidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    updated TEXT,
+    entry_date DATE,
+    event_local_date DATE,
+    event_local_time TIME,
+    remark_text TEXT,
+    event_type_description TEXT,
+    fsdo_description TEXT,
+    flight_number TEXT,
+    aircraft_missing_flag TEXT,
+    aircraft_damage_description TEXT,
+    flight_activity TEXT,
+    flight_phase TEXT,
+    far_part TEXT,
+    fatal_flag TEXT,
+    aircraft_id INTEGER,
+    location_id INTEGER
+);
+INSERT INTO Accidents (id, entry_date, event_local_date, aircraft_id, location_id) VALUES (1, '2023-01-02', '2023-01-01', 1, 1);
+`
+
+// TestMigrate tests that opening a database created by an older schema adds the missing columns, keeps the
+// existing rows and that migrating again does nothing.
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := old.Exec(oldSchema); err != nil {
+		t.Fatalf("Failed to create old schema: %v", err)
+	}
+	old.Close()
+
+	db, err := OpenDB(sqliteScheme + path)
+	if err != nil {
+		t.Fatalf("Expected the old database to be migrated, got %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, m := range migrations {
+		exists, err := columnExists(ctx, db, m.table, m.column)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !exists {
+			t.Errorf("Expected column %s.%s to exist", m.table, m.column)
+		}
+	}
+
+	var failed bool
+	if err := db.QueryRow("SELECT geocode_failed FROM Locations WHERE id = 1").Scan(&failed); err != nil {
+		t.Fatalf("Expected the existing location to be kept, got %v", err)
+	}
+	if failed {
+		t.Errorf("Expected geocode_failed to default to false")
+	}
+
+	var source string
+	if err := db.QueryRow("SELECT source FROM Accidents WHERE id = 1").Scan(&source); err != nil || source != "FAA" {
+		t.Errorf("Expected the existing accident to come from the FAA, got %q (error %v)", source, err)
+	}
+
+	keys, err := db.Query("SELECT registration_number, registration_key FROM Aircrafts ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query aircraft: %v", err)
+	}
+	for keys.Next() {
+		var registration sql.NullString
+		var key string
+		if err := keys.Scan(&registration, &key); err != nil {
+			t.Fatalf("Failed to scan aircraft: %v", err)
+		}
+		if expected := NormalizeRegistration(registration.String); key != expected {
+			t.Errorf("Expected the registration key of %q to be %q, got %q", registration.String, expected, key)
+		}
+	}
+	keys.Close()
+
+	var indexes int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_index_list('Accidents') WHERE name = 'idx_accidents_source'").Scan(&indexes); err != nil || indexes != 1 {
+		t.Errorf("Expected idx_accidents_source to be created, got %d (error %v)", indexes, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_index_list('Aircrafts') WHERE name = 'idx_aircrafts_registration_key'").Scan(&indexes); err != nil || indexes != 1 {
+		t.Errorf("Expected idx_aircrafts_registration_key to be created, got %d (error %v)", indexes, err)
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		t.Errorf("Expected migrating again to succeed, got %v", err)
+	}
+}
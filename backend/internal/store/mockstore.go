@@ -4,18 +4,31 @@
 package store
 
 import (
-	"fmt"
+	"context"
+	"sort"
 
 	"github.com/computers33333/airaccidentdata/internal/models"
 )
 
 // MockStore simulates a data store for testing purposes.
+// Listings return the stored records in order and ignore filters and sorting,
+// which are recorded so tests can assert what a handler asked for.
 type MockStore struct {
 	Aircrafts  []*models.Aircraft
 	Accidents  []*models.Accident
+	Locations  []*models.Location
+	Injuries   []*models.Injury
+	Images     []*models.AircraftImage
+	Ingestions []*models.IngestionRun
 	QueryError error // Used to simulate database query errors
+
+	LastFilter     AccidentFilter // Filter passed to the last GetAccidents call
+	LastSort       Sort           // Sort passed to the last GetAccidents or GetAircrafts call
+	LastPagination Pagination     // Pagination passed to the last GetAccidents, GetAircrafts or GetIngestionRuns call
 }
 
+var _ StoreInterface = (*MockStore)(nil)
+
 // NewMockStore creates a new instance of MockStore.
 func NewMockStore(aircrafts []*models.Aircraft, accidents []*models.Accident, queryError error) *MockStore {
 	return &MockStore{
@@ -25,6 +38,27 @@ func NewMockStore(aircrafts []*models.Aircraft, accidents []*models.Accident, qu
 	}
 }
 
+// queryError returns the error a simulated query fails with: the context's error, if any, otherwise QueryError.
+func (ms *MockStore) queryError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ms.QueryError
+}
+
+// pageBounds returns the slice bounds of a page of n records. Cursors are not supported and always start at the beginning.
+func pageBounds(n int, page Pagination) (int, int) {
+	start := page.offset()
+	if start > n {
+		start = n
+	}
+	end := start + page.Limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
 // Aircraft-related methods
 
 // SaveAircrafts saves aircraft data to the store.
@@ -36,12 +70,103 @@ func (ms *MockStore) SaveAircrafts(aircrafts []*models.Aircraft) error {
 	return nil
 }
 
-// GetAircrafts retrieves aircraft data from the store.
-func (ms *MockStore) GetAircrafts() ([]*models.Aircraft, error) {
-	if ms.QueryError != nil {
-		return nil, ms.QueryError
+// GetAircrafts retrieves a page of aircraft data from the store.
+func (ms *MockStore) GetAircrafts(ctx context.Context, sortBy Sort, page Pagination) ([]*models.Aircraft, int, string, error) {
+	ms.LastSort, ms.LastPagination = sortBy, page
+	if err := ms.queryError(ctx); err != nil {
+		return nil, 0, "", err
+	}
+	start, end := pageBounds(len(ms.Aircrafts), page)
+	return ms.Aircrafts[start:end], len(ms.Aircrafts), "", nil
+}
+
+// GetAircraftById retrieves a specific aircraft by ID from the store.
+func (ms *MockStore) GetAircraftById(ctx context.Context, id int) (*models.Aircraft, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	for _, aircraft := range ms.Aircrafts {
+		if aircraft.ID == id {
+			return aircraft, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAircraftsByRegistration retrieves the aircraft whose registration normalizes to the given one.
+func (ms *MockStore) GetAircraftsByRegistration(ctx context.Context, registration string) ([]*models.Aircraft, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	var aircrafts []*models.Aircraft
+	for _, aircraft := range ms.Aircrafts {
+		if NormalizeRegistration(aircraft.RegistrationNumber) == registration {
+			aircrafts = append(aircrafts, aircraft)
+		}
+	}
+	return aircrafts, nil
+}
+
+// GetAircraftsByIds retrieves the aircraft with the given IDs, keyed by ID.
+func (ms *MockStore) GetAircraftsByIds(ctx context.Context, ids []int) (map[int]*models.Aircraft, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	aircrafts := make(map[int]*models.Aircraft)
+	for _, id := range ids {
+		for _, aircraft := range ms.Aircrafts {
+			if aircraft.ID == id {
+				aircrafts[id] = aircraft
+			}
+		}
+	}
+	return aircrafts, nil
+}
+
+// GetAllImagesForAircraft retrieves all images of an aircraft from the store.
+func (ms *MockStore) GetAllImagesForAircraft(ctx context.Context, aircraftID int) ([]*models.AircraftImage, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	var images []*models.AircraftImage
+	for _, image := range ms.Images {
+		if image.AircraftID == aircraftID {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// GetImageForAircraft retrieves a specific image of an aircraft from the store.
+func (ms *MockStore) GetImageForAircraft(ctx context.Context, aircraftID, imageID int) (*models.AircraftImage, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	for _, image := range ms.Images {
+		if image.AircraftID == aircraftID && image.ID == imageID {
+			return image, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetImagesByAircraftIds retrieves the images of the given aircraft, grouped by aircraft ID.
+func (ms *MockStore) GetImagesByAircraftIds(ctx context.Context, aircraftIDs []int) (map[int][]*models.AircraftImage, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
 	}
-	return ms.Aircrafts, nil
+	images := make(map[int][]*models.AircraftImage)
+	for _, id := range aircraftIDs {
+		if _, ok := images[id]; ok {
+			continue
+		}
+		for _, image := range ms.Images {
+			if image.AircraftID == id {
+				images[id] = append(images[id], image)
+			}
+		}
+	}
+	return images, nil
 }
 
 // Accident-related methods
@@ -55,23 +180,126 @@ func (ms *MockStore) SaveAccidents(accidents []*models.Accident) error {
 	return nil
 }
 
-// GetAccidents retrieves accident data from the store.
-func (ms *MockStore) GetAccidents() ([]*models.Accident, error) {
-	if ms.QueryError != nil {
-		return nil, ms.QueryError
+// GetAccidents retrieves a page of accident data from the store.
+func (ms *MockStore) GetAccidents(ctx context.Context, filter AccidentFilter, sortBy Sort, page Pagination) ([]*models.Accident, int, string, error) {
+	ms.LastFilter, ms.LastSort, ms.LastPagination = filter, sortBy, page
+	if err := ms.queryError(ctx); err != nil {
+		return nil, 0, "", err
+	}
+	start, end := pageBounds(len(ms.Accidents), page)
+	return ms.Accidents[start:end], len(ms.Accidents), "", nil
+}
+
+// GetAccidentsByRegistration retrieves the accidents of the aircraft whose registration normalizes to the given one,
+// oldest first.
+func (ms *MockStore) GetAccidentsByRegistration(ctx context.Context, registration string) ([]*models.Accident, error) {
+	aircrafts, err := ms.GetAircraftsByRegistration(ctx, registration)
+	if err != nil {
+		return nil, err
+	}
+	var accidents []*models.Accident
+	for _, accident := range ms.Accidents {
+		for _, aircraft := range aircrafts {
+			if accident.AircraftID == aircraft.ID {
+				accidents = append(accidents, accident)
+			}
+		}
 	}
-	return ms.Accidents, nil
+	sort.SliceStable(accidents, func(i, j int) bool {
+		return accidents[i].EventLocalDate.Before(accidents[j].EventLocalDate)
+	})
+	return accidents, nil
 }
 
 // GetAccidentById retrieves a specific accident by ID from the store.
-func (ms *MockStore) GetAccidentById(id int) (*models.Accident, error) {
-	if ms.QueryError != nil {
-		return nil, ms.QueryError
+func (ms *MockStore) GetAccidentById(ctx context.Context, id int) (*models.Accident, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
 	}
 	for _, accident := range ms.Accidents {
 		if accident.ID == id {
 			return accident, nil
 		}
 	}
-	return nil, fmt.Errorf("accident not found")
+	return nil, nil
+}
+
+// GetLocationByAccidentId retrieves the location of an accident from the store.
+func (ms *MockStore) GetLocationByAccidentId(ctx context.Context, accidentId int) (*models.Location, error) {
+	accident, err := ms.GetAccidentById(ctx, accidentId)
+	if err != nil || accident == nil {
+		return nil, err
+	}
+	for _, location := range ms.Locations {
+		if location.ID == accident.LocationID {
+			return location, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetLocationsByIds retrieves the locations with the given IDs, keyed by ID.
+func (ms *MockStore) GetLocationsByIds(ctx context.Context, ids []int) (map[int]*models.Location, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	locations := make(map[int]*models.Location)
+	for _, id := range ids {
+		for _, location := range ms.Locations {
+			if location.ID == id {
+				locations[id] = location
+			}
+		}
+	}
+	return locations, nil
+}
+
+// GetInjuriesByAccidentId retrieves the injuries of an accident from the store.
+func (ms *MockStore) GetInjuriesByAccidentId(ctx context.Context, accidentId int) ([]*models.Injury, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	var injuries []*models.Injury
+	for _, injury := range ms.Injuries {
+		if injury.AccidentID == accidentId {
+			injuries = append(injuries, injury)
+		}
+	}
+	return injuries, nil
+}
+
+// GetInjuriesByAccidentIds retrieves the injuries of the given accidents, grouped by accident ID.
+func (ms *MockStore) GetInjuriesByAccidentIds(ctx context.Context, accidentIDs []int) (map[int][]*models.Injury, error) {
+	if err := ms.queryError(ctx); err != nil {
+		return nil, err
+	}
+	injuries := make(map[int][]*models.Injury)
+	for _, id := range accidentIDs {
+		if _, ok := injuries[id]; ok {
+			continue
+		}
+		for _, injury := range ms.Injuries {
+			if injury.AccidentID == id {
+				injuries[id] = append(injuries[id], injury)
+			}
+		}
+	}
+	return injuries, nil
+}
+
+// ExpandAccidents embeds the selected related records into the accidents.
+func (ms *MockStore) ExpandAccidents(ctx context.Context, accidents []*models.Accident, expand Expand) error {
+	return expandAccidents(ctx, ms, accidents, expand)
+}
+
+// Ingestion-related methods
+
+// GetIngestionRuns retrieves a page of ingestion runs from the store, in stored order.
+func (ms *MockStore) GetIngestionRuns(ctx context.Context, page Pagination) ([]*models.IngestionRun, int, error) {
+	ms.LastPagination = page
+	if err := ms.queryError(ctx); err != nil {
+		return nil, 0, err
+	}
+	start, end := pageBounds(len(ms.Ingestions), page)
+	return ms.Ingestions[start:end], len(ms.Ingestions), nil
 }
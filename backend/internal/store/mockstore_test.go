@@ -3,6 +3,7 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -16,7 +17,7 @@ func TestMockStore_GetAircrafts(t *testing.T) {
 	expectedAircrafts := []*models.Aircraft{{RegistrationNumber: "ABC123"}}
 	mockStore := NewMockStore(expectedAircrafts, nil, nil)
 
-	aircrafts, err := mockStore.GetAircrafts()
+	aircrafts, _, _, err := mockStore.GetAircrafts(context.Background(), nil, Pagination{Page: 1, Limit: 10})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -53,7 +54,7 @@ func TestMockStore_SaveAircrafts(t *testing.T) {
 func TestMockStore_GetAircrafts_Error(t *testing.T) {
 	expectedError := errors.New("query error")
 	mockStore := NewMockStore(nil, nil, expectedError)
-	_, err := mockStore.GetAircrafts()
+	_, _, _, err := mockStore.GetAircrafts(context.Background(), nil, Pagination{Page: 1, Limit: 10})
 	if err == nil {
 		t.Fatal("Expected an error, got nil")
 	}
@@ -69,7 +70,7 @@ func TestMockStore_GetAccidents(t *testing.T) {
 	expectedAccidents := []*models.Accident{{ID: 1}}
 	mockStore := NewMockStore(nil, expectedAccidents, nil)
 
-	accidents, err := mockStore.GetAccidents()
+	accidents, _, _, err := mockStore.GetAccidents(context.Background(), AccidentFilter{}, nil, Pagination{Page: 1, Limit: 10})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -117,28 +118,68 @@ func TestMockStore_GetAccidentById(t *testing.T) {
 		expected *models.Accident
 	}{
 		{"Accident exists", 1, false, &models.Accident{ID: 1, AircraftID: 1}},
-		{"Accident does not exist", 3, true, nil},
+		{"Accident does not exist", 3, false, nil},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			accident, err := mockStore.GetAccidentById(tt.id)
+			accident, err := mockStore.GetAccidentById(context.Background(), tt.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetAccidentById() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if err == nil && accident.ID != tt.expected.ID {
-				t.Errorf("GetAccidentById() got = %v, want %v", accident.ID, tt.expected.ID)
+			if tt.expected == nil {
+				if accident != nil {
+					t.Errorf("GetAccidentById() got = %v, want nil", accident.ID)
+				}
+				return
+			}
+			if accident == nil || accident.ID != tt.expected.ID {
+				t.Errorf("GetAccidentById() got = %v, want %v", accident, tt.expected.ID)
 			}
 		})
 	}
 }
 
+// TestMockStore_GetAccidents_Pagination tests that the mock returns the requested page and records its arguments.
+func TestMockStore_GetAccidents_Pagination(t *testing.T) {
+	mockStore := NewMockStore(nil, []*models.Accident{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+	filter := AccidentFilter{FlightPhase: "LANDING"}
+
+	accidents, total, _, err := mockStore.GetAccidents(context.Background(), filter, nil, Pagination{Page: 2, Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if total != 3 || len(accidents) != 1 || accidents[0].ID != 3 {
+		t.Errorf("Expected accident 3 with total 3, got %d accidents with total %d", len(accidents), total)
+	}
+	if mockStore.LastFilter != filter {
+		t.Errorf("Expected filter %+v to be recorded, got %+v", filter, mockStore.LastFilter)
+	}
+}
+
+// TestMockStore_ExpandAccidents tests embedding related records from the mock's data.
+func TestMockStore_ExpandAccidents(t *testing.T) {
+	mockStore := NewMockStore([]*models.Aircraft{{ID: 7, RegistrationNumber: "N7"}}, nil, nil)
+	mockStore.Injuries = []*models.Injury{{ID: 1, AccidentID: 1, Count: 2}}
+	accidents := []*models.Accident{{ID: 1, AircraftID: 7}}
+
+	if err := mockStore.ExpandAccidents(context.Background(), accidents, Expand{Aircraft: true, Injuries: true}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if accidents[0].Aircraft == nil || accidents[0].Aircraft.RegistrationNumber != "N7" {
+		t.Errorf("Expected aircraft N7, got %+v", accidents[0].Aircraft)
+	}
+	if len(accidents[0].Injuries) != 1 {
+		t.Errorf("Expected 1 injury, got %d", len(accidents[0].Injuries))
+	}
+}
+
 // TestMockStore_GetAccidents_Error tests error handling in the GetAccidents method of the MockStore.
 func TestMockStore_GetAccidents_Error(t *testing.T) {
 	expectedError := errors.New("query error")
 	mockStore := NewMockStore(nil, nil, expectedError)
-	_, err := mockStore.GetAccidents()
+	_, _, _, err := mockStore.GetAccidents(context.Background(), AccidentFilter{}, nil, Pagination{Page: 1, Limit: 10})
 	if err == nil {
 		t.Fatal("Expected an error, got nil")
 	}
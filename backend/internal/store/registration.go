@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+)
+
+// NormalizeRegistration returns an aircraft registration in upper case without spaces or dashes, adding the N
+// prefix to US N-numbers written without it, so "N12345", "12345" and "n-12345" all return "N12345".
+func NormalizeRegistration(registration string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(registration)))
+	if normalized != "" && normalized[0] >= '1' && normalized[0] <= '9' {
+		normalized = "N" + normalized
+	}
+	return normalized
+}
+
+// registrationKeySQL returns the SQL expression normalizing a registration column as NormalizeRegistration does,
+// used to fill registration_key for aircraft stored before the column existed.
+func registrationKeySQL(dialect Dialect, column string) string {
+	normalized := "COALESCE(REPLACE(REPLACE(UPPER(" + column + "), '-', ''), ' ', ''), '')"
+	prefixed := "'N' || " + normalized
+	if dialect == DialectMySQL {
+		prefixed = "CONCAT('N', " + normalized + ")"
+	}
+	return "CASE WHEN SUBSTR(" + normalized + ", 1, 1) BETWEEN '1' AND '9' THEN " + prefixed + " ELSE " + normalized + " END"
+}
+
+// registrationCondition returns the condition matching the aircraft, aliased as ac, whose registration normalizes
+// to the given normalized registration however it is written. The importers store the normalized registration of
+// each aircraft in registration_key, which is indexed.
+func registrationCondition(registration string) (string, []interface{}) {
+	return "ac.registration_key = ?", []interface{}{registration}
+}
+
+// GetAircraftsByRegistration fetches every aircraft recorded with a registration, normalized with
+// NormalizeRegistration, ordered by ID. Datasets may record the same aircraft more than once.
+func (s *Store) GetAircraftsByRegistration(ctx context.Context, registration string) ([]*models.Aircraft, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	condition, args := registrationCondition(registration)
+	rows, err := s.db.QueryContext(ctx, selectAircrafts+` WHERE `+condition+` ORDER BY ac.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching aircrafts: %w", err)
+	}
+	defer rows.Close()
+
+	var aircrafts []*models.Aircraft
+	for rows.Next() {
+		aircraft, err := scanAircraft(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning aircraft row: %w", err)
+		}
+		aircrafts = append(aircrafts, aircraft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over aircrafts: %w", err)
+	}
+
+	return aircrafts, nil
+}
+
+// GetAccidentsByRegistration fetches every accident of the aircraft recorded with a registration, normalized with
+// NormalizeRegistration, oldest first.
+func (s *Store) GetAccidentsByRegistration(ctx context.Context, registration string) ([]*models.Accident, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	condition, args := registrationCondition(registration)
+	query := selectAccidents + `
+		WHERE ` + condition + `
+		ORDER BY a.event_local_date, a.event_local_time, a.id`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching accidents: %w", err)
+	}
+	defer rows.Close()
+
+	var accidents []*models.Accident
+	for rows.Next() {
+		accident, err := scanAccident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning accident row: %w", err)
+		}
+		accidents = append(accidents, accident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over accidents: %w", err)
+	}
+
+	return accidents, nil
+}
@@ -0,0 +1,78 @@
+// Registration tests normalize registrations and look up the aircraft and accidents recorded under one.
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestNormalizeRegistration tests that the spellings of a registration normalize to the same value.
+func TestNormalizeRegistration(t *testing.T) {
+	tests := []struct {
+		registration string
+		expected     string
+	}{
+		{"N12345", "N12345"},
+		{"12345", "N12345"},
+		{"n-12345", "N12345"},
+		{" N 777AB ", "N777AB"},
+		{"C-GABC", "CGABC"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRegistration(tt.registration); got != tt.expected {
+			t.Errorf("Expected %q to normalize to %q, got %q", tt.registration, tt.expected, got)
+		}
+	}
+}
+
+// TestStore_GetAccidentsByRegistration tests that aircraft rows sharing a registration written differently are
+// returned together with their accidents, oldest first.
+func TestStore_GetAccidentsByRegistration(t *testing.T) {
+	s := newTestStore(t)
+	seed := []string{
+		`INSERT INTO Aircrafts (id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator, registration_key) VALUES
+			(3, '12345', 'CESSNA', '172M', 'PRIVATE', 'N12345')`,
+		`INSERT INTO Accidents (id, updated, entry_date, event_local_date, event_local_time, remark_text, event_type_description,
+			fsdo_description, flight_number, aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase,
+			far_part, fatal_flag, aircraft_id, location_id) VALUES
+			(4, 'No', '2022-06-02', '2022-06-01', '09:00:00', 'Earlier.', 'Incident', 'FSDO', '', 'No', 'Minor', 'Personal', 'TAXI', '091', '', 3, 1)`,
+	}
+	for _, stmt := range seed {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+
+	aircrafts, err := s.GetAircraftsByRegistration(context.Background(), NormalizeRegistration("12345"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var aircraftIDs []int
+	for _, aircraft := range aircrafts {
+		aircraftIDs = append(aircraftIDs, aircraft.ID)
+	}
+	if !reflect.DeepEqual(aircraftIDs, []int{1, 3}) {
+		t.Errorf("Expected aircraft [1 3], got %v", aircraftIDs)
+	}
+
+	accidents, err := s.GetAccidentsByRegistration(context.Background(), "N12345")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := accidentIDs(accidents); !reflect.DeepEqual(got, []int{4, 1, 3}) {
+		t.Errorf("Expected accidents [4 1 3], got %v", got)
+	}
+
+	_, total, _, err := s.GetAccidents(context.Background(), AccidentFilter{Registration: "N12345"}, nil, Pagination{Page: 1, Limit: 10})
+	if err != nil || total != 3 {
+		t.Errorf("Expected 3 accidents filtered by registration, got %d (error %v)", total, err)
+	}
+
+	aircrafts, err = s.GetAircraftsByRegistration(context.Background(), "N99999")
+	if err != nil || len(aircrafts) != 0 {
+		t.Errorf("Expected no aircraft for N99999, got %d (error %v)", len(aircrafts), err)
+	}
+}
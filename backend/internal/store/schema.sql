@@ -6,7 +6,17 @@ CREATE TABLE IF NOT EXISTS Aircrafts (
     registration_number VARCHAR(255),
     aircraft_make_name VARCHAR(255),
     aircraft_model_name VARCHAR(255),
-    aircraft_operator VARCHAR(255)
+    aircraft_operator VARCHAR(255),
+    year_manufactured SMALLINT,
+    serial_number VARCHAR(30),
+    engine_type VARCHAR(30),
+    number_of_seats SMALLINT,
+    aircraft_category VARCHAR(30),
+    owner_city VARCHAR(50),
+    owner_state VARCHAR(2),
+    registration_key VARCHAR(255) NOT NULL DEFAULT '',
+    INDEX idx_aircrafts_registration (registration_number),
+    INDEX idx_aircrafts_registration_key (registration_key)
 );
 
 CREATE TABLE IF NOT EXISTS Locations (
@@ -15,7 +25,25 @@ CREATE TABLE IF NOT EXISTS Locations (
     state_name VARCHAR(255),
     country_name VARCHAR(255),
     latitude FLOAT,
-    longitude FLOAT
+    longitude FLOAT,
+    geocode_failed BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX idx_locations_place (city_name, state_name, country_name)
+);
+
+CREATE TABLE IF NOT EXISTS IngestionRuns (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    source_url VARCHAR(2048),
+    file_name VARCHAR(1024),
+    file_sha256 CHAR(64),
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_inserted INT NOT NULL DEFAULT 0,
+    rows_updated INT NOT NULL DEFAULT 0,
+    rows_unchanged INT NOT NULL DEFAULT 0,
+    rows_failed INT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    error_message TEXT
 );
 
 CREATE TABLE IF NOT EXISTS Accidents (
@@ -36,8 +64,16 @@ CREATE TABLE IF NOT EXISTS Accidents (
     fatal_flag VARCHAR(50),
     aircraft_id INT,
     location_id INT,
+    ingestion_run_id INT,
+    source VARCHAR(10) NOT NULL DEFAULT 'FAA',
+    source_id VARCHAR(64),
+    linked_accident_id INT,
     FOREIGN KEY (aircraft_id) REFERENCES Aircrafts(id),
-    FOREIGN KEY (location_id) REFERENCES Locations(id)
+    FOREIGN KEY (location_id) REFERENCES Locations(id),
+    FOREIGN KEY (ingestion_run_id) REFERENCES IngestionRuns(id),
+    FOREIGN KEY (linked_accident_id) REFERENCES Accidents(id),
+    INDEX idx_accidents_natural_key (aircraft_id, event_local_date, event_local_time),
+    INDEX idx_accidents_source (source, source_id)
 );
 
 CREATE TABLE IF NOT EXISTS Injuries (
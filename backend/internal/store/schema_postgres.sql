@@ -0,0 +1,96 @@
+-- PostgreSQL equivalent of schema.sql. Text columns use citext to match MySQL's default case-insensitive collation,
+-- and each location also carries a PostGIS geography point derived from its latitude and longitude.
+
+CREATE EXTENSION IF NOT EXISTS citext;
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+CREATE TABLE IF NOT EXISTS Aircrafts (
+    id SERIAL PRIMARY KEY,
+    registration_number CITEXT,
+    aircraft_make_name CITEXT,
+    aircraft_model_name CITEXT,
+    aircraft_operator CITEXT,
+    year_manufactured SMALLINT,
+    serial_number CITEXT,
+    engine_type CITEXT,
+    number_of_seats SMALLINT,
+    aircraft_category CITEXT,
+    owner_city CITEXT,
+    owner_state CITEXT,
+    registration_key VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS Locations (
+    id SERIAL PRIMARY KEY,
+    city_name CITEXT,
+    state_name CITEXT,
+    country_name CITEXT,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    geocode_failed BOOLEAN NOT NULL DEFAULT FALSE,
+    geog GEOGRAPHY(Point, 4326) GENERATED ALWAYS AS (
+        ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography
+    ) STORED
+);
+
+CREATE INDEX IF NOT EXISTS locations_geog_idx ON Locations USING GIST (geog);
+
+CREATE TABLE IF NOT EXISTS IngestionRuns (
+    id SERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ,
+    source_url TEXT,
+    file_name TEXT,
+    file_sha256 CHAR(64),
+    rows_read INT NOT NULL DEFAULT 0,
+    rows_inserted INT NOT NULL DEFAULT 0,
+    rows_updated INT NOT NULL DEFAULT 0,
+    rows_unchanged INT NOT NULL DEFAULT 0,
+    rows_failed INT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    error_message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS Accidents (
+    id SERIAL PRIMARY KEY,
+    updated VARCHAR(255),
+    entry_date DATE,
+    event_local_date DATE,
+    event_local_time TIME,
+    remark_text VARCHAR(1024),
+    event_type_description CITEXT,
+    fsdo_description CITEXT,
+    flight_number VARCHAR(255),
+    aircraft_missing_flag CITEXT,
+    aircraft_damage_description CITEXT,
+    flight_activity CITEXT,
+    flight_phase CITEXT,
+    far_part CITEXT,
+    fatal_flag CITEXT,
+    aircraft_id INT REFERENCES Aircrafts(id),
+    location_id INT REFERENCES Locations(id),
+    ingestion_run_id INT REFERENCES IngestionRuns(id),
+    source CITEXT NOT NULL DEFAULT 'FAA',
+    source_id VARCHAR(64),
+    linked_accident_id INT REFERENCES Accidents(id)
+);
+
+CREATE TABLE IF NOT EXISTS Injuries (
+    id SERIAL PRIMARY KEY,
+    person_type VARCHAR(50),
+    injury_severity VARCHAR(50),
+    count INT,
+    accident_id INT REFERENCES Accidents(id)
+);
+
+CREATE TABLE IF NOT EXISTS AircraftImages (
+    id SERIAL PRIMARY KEY,
+    image_url VARCHAR(2048),
+    s3_url VARCHAR(255),
+    aircraft_id INT REFERENCES Aircrafts(id)
+);
+
+-- Lookups used by the importer to find existing records.
+CREATE INDEX IF NOT EXISTS idx_aircrafts_registration ON Aircrafts (registration_number);
+CREATE INDEX IF NOT EXISTS idx_locations_place ON Locations (city_name, state_name, country_name);
+CREATE INDEX IF NOT EXISTS idx_accidents_natural_key ON Accidents (aircraft_id, event_local_date, event_local_time);
@@ -0,0 +1,93 @@
+-- SQLite equivalent of schema.sql. Text columns use NOCASE to match MySQL's default case-insensitive collation.
+
+CREATE TABLE IF NOT EXISTS Aircrafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    registration_number TEXT COLLATE NOCASE,
+    aircraft_make_name TEXT COLLATE NOCASE,
+    aircraft_model_name TEXT COLLATE NOCASE,
+    aircraft_operator TEXT COLLATE NOCASE,
+    year_manufactured INTEGER,
+    serial_number TEXT COLLATE NOCASE,
+    engine_type TEXT COLLATE NOCASE,
+    number_of_seats INTEGER,
+    aircraft_category TEXT COLLATE NOCASE,
+    owner_city TEXT COLLATE NOCASE,
+    owner_state TEXT COLLATE NOCASE,
+    registration_key TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS Locations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    city_name TEXT COLLATE NOCASE,
+    state_name TEXT COLLATE NOCASE,
+    country_name TEXT COLLATE NOCASE,
+    latitude REAL,
+    longitude REAL,
+    geocode_failed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS IngestionRuns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    source_url TEXT,
+    file_name TEXT,
+    file_sha256 TEXT,
+    rows_read INTEGER NOT NULL DEFAULT 0,
+    rows_inserted INTEGER NOT NULL DEFAULT 0,
+    rows_updated INTEGER NOT NULL DEFAULT 0,
+    rows_unchanged INTEGER NOT NULL DEFAULT 0,
+    rows_failed INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error_message TEXT
+);
+
+CREATE TABLE IF NOT EXISTS Accidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    updated TEXT,
+    entry_date DATE,
+    event_local_date DATE,
+    event_local_time TEXT,
+    remark_text TEXT,
+    event_type_description TEXT COLLATE NOCASE,
+    fsdo_description TEXT COLLATE NOCASE,
+    flight_number TEXT,
+    aircraft_missing_flag TEXT COLLATE NOCASE,
+    aircraft_damage_description TEXT COLLATE NOCASE,
+    flight_activity TEXT COLLATE NOCASE,
+    flight_phase TEXT COLLATE NOCASE,
+    far_part TEXT COLLATE NOCASE,
+    fatal_flag TEXT COLLATE NOCASE,
+    aircraft_id INTEGER,
+    location_id INTEGER,
+    ingestion_run_id INTEGER,
+    source TEXT COLLATE NOCASE NOT NULL DEFAULT 'FAA',
+    source_id TEXT,
+    linked_accident_id INTEGER,
+    FOREIGN KEY (aircraft_id) REFERENCES Aircrafts(id),
+    FOREIGN KEY (location_id) REFERENCES Locations(id),
+    FOREIGN KEY (ingestion_run_id) REFERENCES IngestionRuns(id),
+    FOREIGN KEY (linked_accident_id) REFERENCES Accidents(id)
+);
+
+CREATE TABLE IF NOT EXISTS Injuries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    person_type TEXT,
+    injury_severity TEXT,
+    count INTEGER,
+    accident_id INTEGER,
+    FOREIGN KEY (accident_id) REFERENCES Accidents(id)
+);
+
+CREATE TABLE IF NOT EXISTS AircraftImages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_url TEXT,
+    s3_url TEXT,
+    aircraft_id INTEGER,
+    FOREIGN KEY (aircraft_id) REFERENCES Aircrafts(id)
+);
+
+-- Lookups used by the importer to find existing records.
+CREATE INDEX IF NOT EXISTS idx_aircrafts_registration ON Aircrafts (registration_number);
+CREATE INDEX IF NOT EXISTS idx_locations_place ON Locations (city_name, state_name, country_name);
+CREATE INDEX IF NOT EXISTS idx_accidents_natural_key ON Accidents (aircraft_id, event_local_date, event_local_time);
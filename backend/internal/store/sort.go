@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortField is a single field of a sort order.
+type SortField struct {
+	Name string // API name of the field, e.g. "event_local_date"
+	Desc bool   // Sort descending when true
+}
+
+// Sort is an ordered list of fields to sort a result set by.
+type Sort []SortField
+
+// accidentSortColumns maps sortable accident fields to their SQL columns.
+// Nullable text columns sort as empty strings, matching the values selectAccidents returns, so that cursors
+// taken from rows with NULLs compare the same way the rows were ordered.
+var accidentSortColumns = map[string]string{
+	"id":                          "a.id",
+	"entry_date":                  "a.entry_date",
+	"event_local_date":            "a.event_local_date",
+	"event_type_description":      "COALESCE(a.event_type_description, '')",
+	"aircraft_damage_description": "COALESCE(a.aircraft_damage_description, '')",
+	"flight_phase":                "COALESCE(a.flight_phase, '')",
+	"far_part":                    "COALESCE(a.far_part, '')",
+	"fatal_flag":                  "COALESCE(a.fatal_flag, '')",
+}
+
+// aircraftSortColumns maps sortable aircraft fields to their SQL columns.
+var aircraftSortColumns = map[string]string{
+	"id":                  "ac.id",
+	"registration_number": "ac.registration_number",
+	"aircraft_make_name":  "ac.aircraft_make_name",
+	"aircraft_model_name": "ac.aircraft_model_name",
+	"aircraft_operator":   "ac.aircraft_operator",
+}
+
+// ParseAccidentSort parses a sort expression such as "-event_local_date,id" for accidents.
+func ParseAccidentSort(expr string) (Sort, error) {
+	return parseSort(expr, accidentSortColumns)
+}
+
+// ParseAircraftSort parses a sort expression such as "aircraft_make_name,-id" for aircraft.
+func ParseAircraftSort(expr string) (Sort, error) {
+	return parseSort(expr, aircraftSortColumns)
+}
+
+// parseSort splits a comma separated sort expression, where a leading "-" means descending,
+// and validates every field against the allowed columns.
+func parseSort(expr string, columns map[string]string) (Sort, error) {
+	var sort Sort
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Name: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Name: part[1:], Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			field = SortField{Name: part[1:]}
+		}
+
+		if _, ok := columns[field.Name]; !ok {
+			return nil, fmt.Errorf("unknown sort field %q", field.Name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("duplicate sort field %q", field.Name)
+		}
+		seen[field.Name] = true
+
+		sort = append(sort, field)
+	}
+
+	return sort, nil
+}
+
+// withTiebreaker returns the sort with "id" appended when it is not already present,
+// so that rows with equal sort values always come back in the same order.
+func (s Sort) withTiebreaker() Sort {
+	for _, field := range s {
+		if field.Name == "id" {
+			return s
+		}
+	}
+	return append(append(Sort{}, s...), SortField{Name: "id"})
+}
+
+// orderByClause builds the SQL ORDER BY clause for the sort using the given columns.
+func (s Sort) orderByClause(columns map[string]string) (string, error) {
+	var terms []string
+	for _, field := range s.withTiebreaker() {
+		column, ok := columns[field.Name]
+		if !ok {
+			return "", fmt.Errorf("unknown sort field %q", field.Name)
+		}
+		if field.Desc {
+			terms = append(terms, column+" DESC")
+		} else {
+			terms = append(terms, column+" ASC")
+		}
+	}
+	return "ORDER BY " + strings.Join(terms, ", "), nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import "testing"
+
+// TestParseAccidentSort tests parsing sort expressions into ORDER BY clauses with an id tiebreaker.
+func TestParseAccidentSort(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		wantErr     bool
+		wantOrderBy string
+	}{
+		{"Default order", "", false, "ORDER BY a.id ASC"},
+		{"Descending date with tiebreaker", "-event_local_date", false, "ORDER BY a.event_local_date DESC, a.id ASC"},
+		{"Explicit id keeps its direction", "-event_local_date,-id", false, "ORDER BY a.event_local_date DESC, a.id DESC"},
+		{"Unknown field", "remark_text", true, ""},
+		{"Duplicate field", "id,-id", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortBy, err := ParseAccidentSort(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAccidentSort() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			orderBy, err := sortBy.orderByClause(accidentSortColumns)
+			if err != nil {
+				t.Fatalf("orderByClause() error = %v", err)
+			}
+			if orderBy != tt.wantOrderBy {
+				t.Errorf("orderByClause() = %q, want %q", orderBy, tt.wantOrderBy)
+			}
+		})
+	}
+}
@@ -2,97 +2,197 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/computers33333/airaccidentdata/internal/models"
-	_ "github.com/go-sql-driver/mysql" // Blank identifier imports MySQL driver to initialize and register it.
 )
 
-// StoreInterface defines the methods that our store implementations must have.
+// StoreInterface defines the read operations that our store implementations must have.
+// Single-record lookups return a nil record and a nil error when nothing matches.
 type StoreInterface interface {
-	GetAccidents(page, limit int) ([]*models.Accident, int, error)
-	GetAccidentsByRegistration(registrationNumber string) ([]*models.Accident, error)
-	GetAircraftById(id int) ([]*models.Aircraft, int, error)
-	GetAircrafts(page, limit int) ([]*models.Aircraft, int, error)
-	GetAllImagesForAircraft(aircraftID int) ([]*models.AircraftImage, error)
-	GetImageForAircraft(aircraftID, imageID int) (*models.AircraftImage, error)
+	GetAccidents(ctx context.Context, filter AccidentFilter, sortBy Sort, page Pagination) ([]*models.Accident, int, string, error)
+	GetAccidentById(ctx context.Context, id int) (*models.Accident, error)
+	GetLocationByAccidentId(ctx context.Context, accidentId int) (*models.Location, error)
+	GetInjuriesByAccidentId(ctx context.Context, accidentId int) ([]*models.Injury, error)
+	ExpandAccidents(ctx context.Context, accidents []*models.Accident, expand Expand) error
+
+	GetAircrafts(ctx context.Context, sortBy Sort, page Pagination) ([]*models.Aircraft, int, string, error)
+	GetAircraftById(ctx context.Context, id int) (*models.Aircraft, error)
+	GetAircraftsByRegistration(ctx context.Context, registration string) ([]*models.Aircraft, error)
+	GetAccidentsByRegistration(ctx context.Context, registration string) ([]*models.Accident, error)
+	GetAllImagesForAircraft(ctx context.Context, aircraftID int) ([]*models.AircraftImage, error)
+	GetImageForAircraft(ctx context.Context, aircraftID, imageID int) (*models.AircraftImage, error)
+
+	GetIngestionRuns(ctx context.Context, page Pagination) ([]*models.IngestionRun, int, error)
+
+	GetAircraftsByIds(ctx context.Context, ids []int) (map[int]*models.Aircraft, error)
+	GetLocationsByIds(ctx context.Context, ids []int) (map[int]*models.Location, error)
+	GetInjuriesByAccidentIds(ctx context.Context, accidentIDs []int) (map[int][]*models.Injury, error)
+	GetImagesByAircraftIds(ctx context.Context, aircraftIDs []int) (map[int][]*models.AircraftImage, error)
 }
 
 // Store satisfies the StoreInterface.
 type Store struct {
-	db *sql.DB
+	db           *DB
+	queryTimeout time.Duration // Maximum duration of a single query, 0 for no limit
 }
 
-// NewStore establishes a new MYSQL database connection.
-func NewStore(dataSourceName string) (*Store, error) {
-	db, err := sql.Open("mysql", dataSourceName)
+var _ StoreInterface = (*Store)(nil)
+
+// NewStore establishes a new database connection to MySQL, PostgreSQL or a SQLite file, depending on the data source name.
+// Each query is cancelled after queryTimeout, or when the caller's context is done; a zero timeout disables the limit.
+func NewStore(dataSourceName string, queryTimeout time.Duration) (*Store, error) {
+	db, err := OpenDB(dataSourceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	return &Store{db: db, queryTimeout: queryTimeout}, nil
+}
+
+// withTimeout derives the context for a single query from the caller's context.
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
 
-	return &Store{db: db}, nil
+// Close closes the underlying database connection.
+func (s *Store) Close() error {
+	return s.db.Close()
 }
 
-// GetAircrafts fetches a specific page of aircrafts from the database with pagination.
-func (s *Store) GetAircrafts(page, limit int) ([]*models.Aircraft, int, error) {
-	offset := (page - 1) * limit
+// selectAircrafts selects the columns read by scanAircraft, with Aircrafts aliased as ac.
+const selectAircrafts = `
+	SELECT ac.id, ac.registration_number, ac.aircraft_make_name, ac.aircraft_model_name, ac.aircraft_operator,
+		ac.year_manufactured, COALESCE(ac.serial_number, ''), COALESCE(ac.engine_type, ''), ac.number_of_seats,
+		COALESCE(ac.aircraft_category, ''), COALESCE(ac.owner_city, ''), COALESCE(ac.owner_state, '')
+	FROM Aircrafts ac`
 
-	query := `
-		SELECT id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator
-		FROM Aircrafts
-		LIMIT ? OFFSET ? 
-	`
+// scanAircraft scans a row selected by selectAircrafts.
+func scanAircraft(row rowScanner) (*models.Aircraft, error) {
+	var aircraft models.Aircraft
+	err := row.Scan(
+		&aircraft.ID, &aircraft.RegistrationNumber, &aircraft.AircraftMakeName, &aircraft.AircraftModelName,
+		&aircraft.AircraftOperator, &aircraft.YearManufactured, &aircraft.SerialNumber, &aircraft.EngineType,
+		&aircraft.NumberOfSeats, &aircraft.AircraftCategory, &aircraft.OwnerCity, &aircraft.OwnerState,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &aircraft, nil
+}
 
-	rows, err := s.db.Query(query, limit, offset)
+// selectAccidents selects the columns read by scanAccident, with Accidents aliased as a and joined to Aircrafts
+// as ac and Locations as l for filtering.
+const selectAccidents = `
+	SELECT
+		a.id, a.updated, a.entry_date, a.event_local_date, a.event_local_time,
+		a.remark_text, COALESCE(a.event_type_description, ''), a.fsdo_description, a.flight_number,
+		a.aircraft_missing_flag, COALESCE(a.aircraft_damage_description, ''), a.flight_activity,
+		COALESCE(a.flight_phase, ''), COALESCE(a.far_part, ''), COALESCE(a.fatal_flag, ''),
+		a.location_id, a.aircraft_id, a.ingestion_run_id, a.source, COALESCE(a.source_id, ''), a.linked_accident_id
+	FROM Accidents a
+	LEFT JOIN Aircrafts ac ON ac.id = a.aircraft_id
+	LEFT JOIN Locations l ON l.id = a.location_id`
+
+// scanAccident scans a row selected by selectAccidents.
+func scanAccident(row rowScanner) (*models.Accident, error) {
+	var accident models.Accident
+	err := row.Scan(
+		&accident.ID, &accident.Updated, &accident.EntryDate, &accident.EventLocalDate,
+		&accident.EventLocalTime, &accident.RemarkText, &accident.EventTypeDescription,
+		&accident.FSDODescription, &accident.FlightNumber, &accident.AircraftMissingFlag,
+		&accident.AircraftDamageDescription, &accident.FlightActivity, &accident.FlightPhase,
+		&accident.FARPart, &accident.FatalFlag, &accident.LocationID, &accident.AircraftID, &accident.IngestedBy,
+		&accident.Source, &accident.SourceID, &accident.LinkedAccidentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &accident, nil
+}
+
+// GetAircrafts fetches a page of aircrafts from the database, using either offset or cursor pagination.
+// The total count is only computed for offset pagination and is 0 when a cursor is given.
+func (s *Store) GetAircrafts(ctx context.Context, sortBy Sort, page Pagination) ([]*models.Aircraft, int, string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	orderBy, err := sortBy.orderByClause(aircraftSortColumns)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	where := ""
+	var args []interface{}
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, sortBy)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		keyset, keysetArgs := sortBy.keysetClause(aircraftSortColumns, values)
+		where = andWhere(where, keyset)
+		args = append(args, keysetArgs...)
+	}
+
+	// Fetch one extra row to find out whether there is a next page.
+	query := selectAircrafts + `
+		` + where + `
+		` + orderBy + `
+		LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, page.Limit+1, page.offset())...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error fetching aircrafts: %w", err)
+		return nil, 0, "", fmt.Errorf("error fetching aircrafts: %w", err)
 	}
 	defer rows.Close()
 
 	var aircrafts []*models.Aircraft
 	for rows.Next() {
-		var aircraft models.Aircraft
-		if err := rows.Scan(
-			&aircraft.ID,
-			&aircraft.RegistrationNumber,
-			&aircraft.AircraftMakeName,
-			&aircraft.AircraftModelName,
-			&aircraft.AircraftOperator,
-		); err != nil {
-			return nil, 0, fmt.Errorf("error scanning aircraft row: %w", err)
+		aircraft, err := scanAircraft(rows)
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("error scanning aircraft row: %w", err)
 		}
-		aircrafts = append(aircrafts, &aircraft)
+		aircrafts = append(aircrafts, aircraft)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, "", fmt.Errorf("error iterating over aircrafts: %w", err)
+	}
+
+	var nextCursor string
+	if len(aircrafts) > page.Limit {
+		aircrafts = aircrafts[:page.Limit]
+		nextCursor = encodeCursor(sortBy, aircraftSortValues(aircrafts[len(aircrafts)-1], sortBy))
+	}
+
+	if page.Cursor != "" {
+		return aircrafts, 0, nextCursor, nil
 	}
 
 	countQuery := `SELECT COUNT(*) FROM Aircrafts`
 	var totalCount int
-	err = s.db.QueryRow(countQuery).Scan(&totalCount)
+	err = s.db.QueryRowContext(ctx, countQuery).Scan(&totalCount)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error fetching total number of accidents: %w", err)
+		return nil, 0, "", fmt.Errorf("error fetching total number of aircrafts: %w", err)
 	}
 
-	return aircrafts, totalCount, nil
+	return aircrafts, totalCount, nextCursor, nil
 }
 
 // GetAircraftById fetches an aircraft by its ID from the database.
-func (s *Store) GetAircraftById(id int) (*models.Aircraft, error) {
-	query := `SELECT id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator FROM Aircrafts WHERE id = ?`
+func (s *Store) GetAircraftById(ctx context.Context, id int) (*models.Aircraft, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, selectAircrafts+` WHERE ac.id = ?`, id)
 
-	var aircraft models.Aircraft
-	err := row.Scan(
-		&aircraft.ID,
-		&aircraft.RegistrationNumber,
-		&aircraft.AircraftMakeName,
-		&aircraft.AircraftModelName,
-		&aircraft.AircraftOperator,
-	)
+	aircraft, err := scanAircraft(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -100,80 +200,94 @@ func (s *Store) GetAircraftById(id int) (*models.Aircraft, error) {
 		return nil, fmt.Errorf("error scanning aircraft row: %w", err)
 	}
 
-	return &aircraft, nil
+	return aircraft, nil
 }
 
-// GetAccidents fetches a specific page of aircraft accidents from the database.
-func (s *Store) GetAccidents(page, limit int) ([]*models.Accident, int, error) {
+// GetAccidents fetches a page of aircraft accidents matching the filter from the database,
+// using either offset or cursor pagination.
+// The total count is only computed for offset pagination and is 0 when a cursor is given.
+func (s *Store) GetAccidents(ctx context.Context, filter AccidentFilter, sortBy Sort, page Pagination) ([]*models.Accident, int, string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	var accidents []*models.Accident
-	offset := (page - 1) * limit
-	query := `
-		SELECT 
-			id, updated, entry_date, event_local_date, event_local_time,
-			remark_text, event_type_description, fsdo_description, flight_number, 
-			aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase, 
-			far_part, fatal_flag, location_id, aircraft_id
-		FROM Accidents
-		ORDER BY id LIMIT ? OFFSET ?;
+	where, args := filter.whereClause()
+	orderBy, err := sortBy.orderByClause(accidentSortColumns)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	pageWhere := where
+	pageArgs := append([]interface{}{}, args...)
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, sortBy)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		keyset, keysetArgs := sortBy.keysetClause(accidentSortColumns, values)
+		pageWhere = andWhere(pageWhere, keyset)
+		pageArgs = append(pageArgs, keysetArgs...)
+	}
+
+	// Fetch one extra row to find out whether there is a next page.
+	query := selectAccidents + `
+		` + pageWhere + `
+		` + orderBy + `
+		LIMIT ? OFFSET ?;
 	`
 
-	rows, err := s.db.Query(query, limit, offset)
+	rows, err := s.db.QueryContext(ctx, query, append(pageArgs, page.Limit+1, page.offset())...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("query execution error: %w", err)
+		return nil, 0, "", fmt.Errorf("query execution error: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var accident models.Accident
-		if err := rows.Scan(
-			&accident.ID, &accident.Updated, &accident.EntryDate, &accident.EventLocalDate,
-			&accident.EventLocalTime, &accident.RemarkText,
-			&accident.EventTypeDescription, &accident.FSDODescription, &accident.FlightNumber,
-			&accident.AircraftMissingFlag, &accident.AircraftDamageDescription, &accident.FlightActivity,
-			&accident.FlightPhase, &accident.FARPart, &accident.FatalFlag,
-			&accident.LocationID, &accident.AircraftID,
-		); err != nil {
-			return nil, 0, err
+		accident, err := scanAccident(rows)
+		if err != nil {
+			return nil, 0, "", err
 		}
 
-		accidents = append(accidents, &accident)
+		accidents = append(accidents, accident)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
+	}
+
+	var nextCursor string
+	if len(accidents) > page.Limit {
+		accidents = accidents[:page.Limit]
+		nextCursor = encodeCursor(sortBy, accidentSortValues(accidents[len(accidents)-1], sortBy))
+	}
+
+	if page.Cursor != "" {
+		return accidents, 0, nextCursor, nil
 	}
 
 	var totalCount int
-	countQuery := "SELECT COUNT(*) FROM Accidents;"
-	err = s.db.QueryRow(countQuery).Scan(&totalCount)
+	countQuery := `
+		SELECT COUNT(*)
+		FROM Accidents a
+		LEFT JOIN Aircrafts ac ON ac.id = a.aircraft_id
+		LEFT JOIN Locations l ON l.id = a.location_id
+		` + where + `;`
+	err = s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
 	if err != nil {
-		return nil, 0, fmt.Errorf("count query error: %w", err)
+		return nil, 0, "", fmt.Errorf("count query error: %w", err)
 	}
 
-	return accidents, totalCount, nil
+	return accidents, totalCount, nextCursor, nil
 }
 
 // GetAccidentById fetches an accident by its ID from the database.
-func (s *Store) GetAccidentById(id int) (*models.Accident, error) {
-	query := `
-		SELECT 
-			id, updated, entry_date, event_local_date, event_local_time, remark_text, event_type_description, fsdo_description,
-			flight_number, aircraft_missing_flag, aircraft_damage_description,
-			flight_activity, flight_phase, far_part, fatal_flag, location_id, aircraft_id
-		FROM Accidents
-		WHERE id = ?;
-	`
+func (s *Store) GetAccidentById(ctx context.Context, id int) (*models.Accident, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	row := s.db.QueryRow(query, id)
+	row := s.db.QueryRowContext(ctx, selectAccidents+` WHERE a.id = ?`, id)
 
-	var accident models.Accident
-	err := row.Scan(
-		&accident.ID, &accident.Updated, &accident.EntryDate, &accident.EventLocalDate,
-		&accident.EventLocalTime, &accident.RemarkText, &accident.EventTypeDescription,
-		&accident.FSDODescription, &accident.FlightNumber, &accident.AircraftMissingFlag,
-		&accident.AircraftDamageDescription, &accident.FlightActivity, &accident.FlightPhase,
-		&accident.FARPart, &accident.FatalFlag, &accident.LocationID, &accident.AircraftID,
-	)
+	accident, err := scanAccident(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return nil for the accident if not found.
@@ -184,21 +298,24 @@ func (s *Store) GetAccidentById(id int) (*models.Accident, error) {
 	}
 
 	// Return the scanned accident.
-	return &accident, nil
+	return accident, nil
 }
 
 // GetLocationByAccidentId retrieves location details based on an accident's location ID.
-func (s *Store) GetLocationByAccidentId(accidentId int) (*models.Location, error) {
+func (s *Store) GetLocationByAccidentId(ctx context.Context, accidentId int) (*models.Location, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-    SELECT Locations.id, Locations.city_name, Locations.state_name, Locations.country_name, Locations.latitude, Locations.longitude
+    SELECT Locations.id, Locations.city_name, Locations.state_name, Locations.country_name, Locations.latitude, Locations.longitude, Locations.geocode_failed
     FROM Locations
     JOIN Accidents ON Locations.id = Accidents.location_id
     WHERE Accidents.id = ?;
     `
-	row := s.db.QueryRow(query, accidentId)
+	row := s.db.QueryRowContext(ctx, query, accidentId)
 
 	var location models.Location
-	err := row.Scan(&location.ID, &location.CityName, &location.StateName, &location.CountryName, &location.Latitude, &location.Longitude)
+	err := row.Scan(&location.ID, &location.CityName, &location.StateName, &location.CountryName, &location.Latitude, &location.Longitude, &location.GeocodeFailed)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -209,9 +326,12 @@ func (s *Store) GetLocationByAccidentId(accidentId int) (*models.Location, error
 }
 
 // GetAllImagesForAircraft fetches all images associated with an aircraft by its ID.
-func (s *Store) GetAllImagesForAircraft(aircraftID int) ([]*models.AircraftImage, error) {
+func (s *Store) GetAllImagesForAircraft(ctx context.Context, aircraftID int) ([]*models.AircraftImage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `SELECT id, aircraft_id, image_url, COALESCE(s3_url, '') AS s3_url FROM AircraftImages WHERE aircraft_id = ?`
-	rows, err := s.db.Query(query, aircraftID)
+	rows, err := s.db.QueryContext(ctx, query, aircraftID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching aircraft images: %w", err)
 	}
@@ -235,10 +355,13 @@ func (s *Store) GetAllImagesForAircraft(aircraftID int) ([]*models.AircraftImage
 }
 
 // GetImageForAircraft fetches a specific image associated with an aircraft by its ID.
-func (s *Store) GetImageForAircraft(aircraftID, imageID int) (*models.AircraftImage, error) {
-	query := `SELECT id, aircraft_id, image_url, s3_url FROM AircraftImages WHERE aircraft_id = ? AND id = ?`
+func (s *Store) GetImageForAircraft(ctx context.Context, aircraftID, imageID int) (*models.AircraftImage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	row := s.db.QueryRow(query, aircraftID, imageID)
+	query := `SELECT id, aircraft_id, image_url, COALESCE(s3_url, '') AS s3_url FROM AircraftImages WHERE aircraft_id = ? AND id = ?`
+
+	row := s.db.QueryRowContext(ctx, query, aircraftID, imageID)
 
 	var image models.AircraftImage
 	err := row.Scan(&image.ID, &image.AircraftID, &image.ImageURL, &image.S3URL)
@@ -252,10 +375,13 @@ func (s *Store) GetImageForAircraft(aircraftID, imageID int) (*models.AircraftIm
 	return &image, nil
 }
 
-// GetInjuriesByAccidentIdHandler fetches all injuries associated with a specific accident by its ID.
-func (s *Store) GetInjuriesByAccidentIdHandler(accidentId int) ([]*models.Injury, error) {
+// GetInjuriesByAccidentId fetches all injuries associated with a specific accident by its ID.
+func (s *Store) GetInjuriesByAccidentId(ctx context.Context, accidentId int) ([]*models.Injury, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `SELECT id, person_type, injury_severity, count, accident_id FROM Injuries WHERE accident_id = ?`
-	rows, err := s.db.Query(query, accidentId)
+	rows, err := s.db.QueryContext(ctx, query, accidentId)
 	if err != nil {
 		return nil, fmt.Errorf("error querying injuries: %w", err)
 	}
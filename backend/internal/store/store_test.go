@@ -0,0 +1,374 @@
+// Store tests run the SQL store against a temporary SQLite database,
+// so the queries are exercised without a MySQL server.
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/computers33333/airaccidentdata/internal/models"
+)
+
+// newTestStore opens a store on a fresh SQLite file seeded with three accidents.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := NewStore("sqlite://"+filepath.Join(t.TempDir(), "test.db"), 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	seed := []string{
+		`INSERT INTO Aircrafts (id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator, registration_key) VALUES
+			(1, 'N12345', 'CESSNA', '172', 'PRIVATE', 'N12345'),
+			(2, 'N67890', 'PIPER', 'PA28', 'FLIGHT SCHOOL', 'N67890')`,
+		`INSERT INTO Locations (id, city_name, state_name, country_name, latitude, longitude) VALUES
+			(1, 'AUSTIN', 'Texas', 'United States', 30.27, -97.74),
+			(2, 'DENVER', 'Colorado', 'United States', 39.74, -104.99)`,
+		`INSERT INTO Accidents (id, updated, entry_date, event_local_date, event_local_time, remark_text, event_type_description,
+			fsdo_description, flight_number, aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase,
+			far_part, fatal_flag, aircraft_id, location_id) VALUES
+			(1, 'No', '2023-01-02', '2023-01-01', '10:00:00', 'First.', 'Accident', 'FSDO', '', 'No', 'Substantial', 'Personal', 'LANDING', '091', '', 1, 1),
+			(2, 'No', '2023-02-02', '2023-02-01', '11:00:00', 'Second.', 'Incident', 'FSDO', '', 'No', 'Minor', 'Personal', 'TAKEOFF', '091', 'Yes', 2, 2),
+			(3, 'No', '2023-02-02', '2023-02-01', '12:00:00', 'Third.', 'Accident', 'FSDO', '', 'No', 'Substantial', 'Instruction', 'LANDING', '091', '', 1, 2)`,
+		`INSERT INTO Injuries (person_type, injury_severity, count, accident_id) VALUES
+			('passengers', 'minor', 2, 1),
+			('flight_crew', 'fatal', 1, 2)`,
+		`INSERT INTO AircraftImages (image_url, s3_url, aircraft_id) VALUES ('https://example.com/172.jpg', NULL, 1)`,
+	}
+	for _, stmt := range seed {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+
+	return s
+}
+
+// accidentIDs returns the IDs of the accidents, in order.
+func accidentIDs(accidents []*models.Accident) []int {
+	var ids []int
+	for _, accident := range accidents {
+		ids = append(ids, accident.ID)
+	}
+	return ids
+}
+
+// TestStore_GetAccidents_Filter tests that filters are combined with AND and reflected in the total.
+func TestStore_GetAccidents_Filter(t *testing.T) {
+	s := newTestStore(t)
+
+	filter := AccidentFilter{FlightPhase: "landing", StateName: "Colorado"}
+	accidents, total, _, err := s.GetAccidents(context.Background(), filter, nil, Pagination{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if total != 1 || len(accidents) != 1 || accidents[0].ID != 3 {
+		t.Errorf("Expected only accident 3 with total 1, got %v with total %d", accidentIDs(accidents), total)
+	}
+
+	to := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	filter = AccidentFilter{EventDateTo: &to, FatalFlag: "No"}
+	accidents, total, _, err = s.GetAccidents(context.Background(), filter, nil, Pagination{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if total != 2 || len(accidents) != 2 {
+		t.Errorf("Expected accidents 1 and 3 with total 2, got %v with total %d", accidentIDs(accidents), total)
+	}
+}
+
+// TestStore_GetAccidents_Cursor tests walking a sorted listing page by page with cursors.
+func TestStore_GetAccidents_Cursor(t *testing.T) {
+	s := newTestStore(t)
+
+	sortBy, err := ParseAccidentSort("-event_local_date")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var ids []int
+	page := Pagination{Page: 1, Limit: 2}
+	for {
+		accidents, _, nextCursor, err := s.GetAccidents(context.Background(), AccidentFilter{}, sortBy, page)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		ids = append(ids, accidentIDs(accidents)...)
+		if nextCursor == "" {
+			break
+		}
+		page = Pagination{Limit: 2, Cursor: nextCursor}
+	}
+
+	expected := []int{2, 3, 1}
+	if len(ids) != len(expected) {
+		t.Fatalf("Expected accidents %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Fatalf("Expected accidents %v, got %v", expected, ids)
+		}
+	}
+}
+
+// TestStore_GetAccidents_CursorNulls tests that cursors on nullable sort columns neither skip nor repeat rows
+// whose value is NULL.
+func TestStore_GetAccidents_CursorNulls(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.db.Exec(`UPDATE Accidents SET flight_phase = NULL, far_part = NULL, fatal_flag = NULL WHERE id IN (1, 3)`); err != nil {
+		t.Fatalf("Failed to clear sort columns: %v", err)
+	}
+
+	tests := []struct {
+		sort     string
+		expected []int
+	}{
+		{"flight_phase", []int{1, 3, 2}},
+		{"-flight_phase", []int{2, 1, 3}},
+		{"far_part,-id", []int{3, 1, 2}},
+		{"-fatal_flag,-id", []int{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			sortBy, err := ParseAccidentSort(tt.sort)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			var ids []int
+			page := Pagination{Page: 1, Limit: 1}
+			for len(ids) <= len(tt.expected) {
+				accidents, _, nextCursor, err := s.GetAccidents(context.Background(), AccidentFilter{}, sortBy, page)
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				ids = append(ids, accidentIDs(accidents)...)
+				if nextCursor == "" {
+					break
+				}
+				page = Pagination{Limit: 1, Cursor: nextCursor}
+			}
+
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected accidents %v, got %v", tt.expected, ids)
+			}
+			for i := range tt.expected {
+				if ids[i] != tt.expected[i] {
+					t.Fatalf("Expected accidents %v, got %v", tt.expected, ids)
+				}
+			}
+		})
+	}
+}
+
+// TestStore_ExpandAccidents tests embedding aircraft, images, locations and injuries.
+func TestStore_ExpandAccidents(t *testing.T) {
+	s := newTestStore(t)
+
+	accident, err := s.GetAccidentById(context.Background(), 1)
+	if err != nil || accident == nil {
+		t.Fatalf("Expected accident 1, got %v (error %v)", accident, err)
+	}
+
+	expand, _ := ParseExpand("images,location,injuries")
+	if err := s.ExpandAccidents(context.Background(), []*models.Accident{accident}, expand); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if accident.Aircraft == nil || accident.Aircraft.RegistrationNumber != "N12345" {
+		t.Errorf("Expected aircraft N12345, got %+v", accident.Aircraft)
+	}
+	if accident.Aircraft != nil && len(accident.Aircraft.Images) != 1 {
+		t.Errorf("Expected 1 image, got %d", len(accident.Aircraft.Images))
+	}
+	if accident.Location == nil || accident.Location.CityName != "AUSTIN" {
+		t.Errorf("Expected location AUSTIN, got %+v", accident.Location)
+	}
+	if len(accident.Injuries) != 1 || accident.Injuries[0].Count != 2 {
+		t.Errorf("Expected 1 injury record with count 2, got %+v", accident.Injuries)
+	}
+}
+
+// TestStore_GetAircraftById tests that registry details are returned when known and omitted otherwise.
+func TestStore_GetAircraftById(t *testing.T) {
+	s := newTestStore(t)
+	_, err := s.db.Exec(`UPDATE Aircrafts SET year_manufactured = 1975, serial_number = '17265432', engine_type = 'Reciprocating',
+		number_of_seats = 4, aircraft_category = 'Fixed wing single engine', owner_city = 'AUSTIN', owner_state = 'TX' WHERE id = 1`)
+	if err != nil {
+		t.Fatalf("Failed to update aircraft: %v", err)
+	}
+
+	aircraft, err := s.GetAircraftById(context.Background(), 1)
+	if err != nil || aircraft == nil {
+		t.Fatalf("Expected aircraft 1, got %v (error %v)", aircraft, err)
+	}
+	if aircraft.YearManufactured == nil || *aircraft.YearManufactured != 1975 || aircraft.SerialNumber != "17265432" ||
+		aircraft.EngineType != "Reciprocating" || aircraft.NumberOfSeats == nil || *aircraft.NumberOfSeats != 4 ||
+		aircraft.AircraftCategory != "Fixed wing single engine" || aircraft.OwnerCity != "AUSTIN" || aircraft.OwnerState != "TX" {
+		t.Errorf("Expected the registry details of aircraft 1, got %+v", aircraft)
+	}
+
+	aircraft, err = s.GetAircraftById(context.Background(), 2)
+	if err != nil || aircraft == nil {
+		t.Fatalf("Expected aircraft 2, got %v (error %v)", aircraft, err)
+	}
+	if aircraft.YearManufactured != nil || aircraft.NumberOfSeats != nil || aircraft.SerialNumber != "" {
+		t.Errorf("Expected no registry details for aircraft 2, got %+v", aircraft)
+	}
+}
+
+// TestStore_GetAircrafts tests that listed aircraft carry their registry details, walking a sorted listing with a
+// cursor.
+func TestStore_GetAircrafts(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.db.Exec(`UPDATE Aircrafts SET year_manufactured = 1975, owner_state = 'TX' WHERE id = 1`); err != nil {
+		t.Fatalf("Failed to update aircraft: %v", err)
+	}
+
+	sortBy, err := ParseAircraftSort("-registration_number")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	aircrafts, total, nextCursor, err := s.GetAircrafts(context.Background(), sortBy, Pagination{Page: 1, Limit: 1})
+	if err != nil || total != 2 || len(aircrafts) != 1 || aircrafts[0].ID != 2 || nextCursor == "" {
+		t.Fatalf("Expected aircraft 2 of 2 with a next cursor, got %+v with total %d (error %v)", aircrafts, total, err)
+	}
+
+	aircrafts, _, nextCursor, err = s.GetAircrafts(context.Background(), sortBy, Pagination{Limit: 1, Cursor: nextCursor})
+	if err != nil || len(aircrafts) != 1 || aircrafts[0].ID != 1 || nextCursor != "" {
+		t.Fatalf("Expected aircraft 1 on the last page, got %+v (error %v)", aircrafts, err)
+	}
+	if aircrafts[0].YearManufactured == nil || *aircrafts[0].YearManufactured != 1975 || aircrafts[0].OwnerState != "TX" {
+		t.Errorf("Expected the registry details of aircraft 1, got %+v", aircrafts[0])
+	}
+}
+
+// TestStore_IngestionRuns tests that recorded runs are listed newest first with their outcome, and linked from accidents.
+func TestStore_IngestionRuns(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for _, status := range []string{IngestionSucceeded, IngestionFailed} {
+		run := &models.IngestionRun{SourceURL: "https://example.com/faa", FileName: "faa.csv", FileSHA256: "abc"}
+		if err := StartIngestionRun(ctx, s.db, run); err != nil {
+			t.Fatalf("Failed to start run: %v", err)
+		}
+		run.RowsRead, run.RowsInserted, run.RowsFailed, run.Status = 3, 2, 1, status
+		if status == IngestionFailed {
+			run.Error = "rejected 33.3% of records"
+		}
+		if err := FinishIngestionRun(ctx, s.db, run); err != nil {
+			t.Fatalf("Failed to finish run: %v", err)
+		}
+	}
+
+	runs, total, err := s.GetIngestionRuns(ctx, Pagination{Page: 1, Limit: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if total != 2 || len(runs) != 1 {
+		t.Fatalf("Expected 1 of 2 runs, got %d of %d", len(runs), total)
+	}
+	run := runs[0]
+	if run.ID != 2 || run.Status != IngestionFailed || run.Error == "" || run.FinishedAt == nil || run.RowsInserted != 2 || run.FileName != "faa.csv" {
+		t.Errorf("Expected the failed run 2 with its counts, got %+v", run)
+	}
+
+	last, err := SucceededIngestionRunBySHA256(ctx, s.db, "abc")
+	if err != nil || last == nil || last.ID != 1 {
+		t.Errorf("Expected run 1 to be the last that succeeded, got %+v, %v", last, err)
+	}
+	if last, err := SucceededIngestionRunBySHA256(ctx, s.db, "def"); err != nil || last != nil {
+		t.Errorf("Expected no run for another file, got %+v, %v", last, err)
+	}
+
+	if _, err := s.db.Exec("UPDATE Accidents SET ingestion_run_id = 1 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to link accident: %v", err)
+	}
+	accident, err := s.GetAccidentById(ctx, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if accident.IngestedBy == nil || *accident.IngestedBy != 1 {
+		t.Errorf("Expected accident ingested by run 1, got %v", accident.IngestedBy)
+	}
+}
+
+// TestTryAdvisoryLock tests that a lock is exclusive until it is released.
+func TestTryAdvisoryLock(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	lock, err := TryAdvisoryLock(ctx, s.db, "test-lock")
+	if err != nil || lock == nil {
+		t.Fatalf("Expected the lock to be taken, got %v, %v", lock, err)
+	}
+	if other, err := TryAdvisoryLock(ctx, s.db, "test-lock"); err != nil || other != nil {
+		t.Errorf("Expected the held lock to be refused, got %v, %v", other, err)
+	}
+	if other, err := TryAdvisoryLock(ctx, s.db, "other-lock"); err != nil || other == nil {
+		t.Errorf("Expected another lock to be taken, got %v, %v", other, err)
+	} else {
+		other.Release()
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Expected no error releasing the lock, got %v", err)
+	}
+	lock, err = TryAdvisoryLock(ctx, s.db, "test-lock")
+	if err != nil || lock == nil {
+		t.Fatalf("Expected the released lock to be taken again, got %v, %v", lock, err)
+	}
+	lock.Release()
+}
+
+// TestLinkNTSBAccidents tests that NTSB accidents are linked to the FAA accident of an aircraft with the same
+// registration key on the same date, once, including FAA registrations written without the N prefix.
+func TestLinkNTSBAccidents(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	seed := []string{
+		`INSERT INTO Aircrafts (id, registration_number, aircraft_make_name, aircraft_model_name, aircraft_operator, registration_key) VALUES
+			(3, 'N12345', 'CESSNA', '172S', '', 'N12345'),
+			(4, '555', 'PIPER', 'PA28', '', 'N555'),
+			(5, 'N555', 'PIPER', 'PA-28-181', '', 'N555')`,
+		`INSERT INTO Accidents (id, updated, entry_date, event_local_date, event_local_time, remark_text, event_type_description,
+			fsdo_description, flight_number, aircraft_missing_flag, aircraft_damage_description, flight_activity, flight_phase,
+			far_part, fatal_flag, aircraft_id, location_id, source, source_id) VALUES
+			(4, '', '2023-01-05', '2023-01-01', '10:00:00', 'Cause.', 'Accident', '', '', 'No', 'Substantial', 'Personal', '', '091', '', 3, 1, 'NTSB', '20230101X00001-1'),
+			(5, '', '2023-03-05', '2023-03-01', '10:00:00', 'Cause.', 'Accident', '', '', 'No', 'Substantial', 'Personal', '', '091', '', 1, 1, 'NTSB', '20230301X00002-1'),
+			(6, '', '2023-04-05', '2023-04-01', '10:00:00', 'Remark.', 'Incident', '', '', 'No', 'Minor', 'Personal', '', '091', '', 4, 1, 'FAA', NULL),
+			(7, '', '2023-04-05', '2023-04-01', '10:00:00', 'Cause.', 'Incident', '', '', 'No', 'Minor', 'Personal', '', '091', '', 5, 1, 'NTSB', '20230401X00003-1')`,
+	}
+	for _, stmt := range seed {
+		if _, err := s.db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to seed database: %v", err)
+		}
+	}
+
+	linked, err := LinkNTSBAccidents(ctx, s.db)
+	if err != nil || linked != 2 {
+		t.Fatalf("Expected 2 accidents linked, got %d, %v", linked, err)
+	}
+	accident, err := s.GetAccidentById(ctx, 4)
+	if err != nil || accident.Source != SourceNTSB || accident.SourceID != "20230101X00001-1" || accident.LinkedAccidentID == nil || *accident.LinkedAccidentID != 1 {
+		t.Errorf("Expected NTSB accident 4 linked to accident 1, got %+v, %v", accident, err)
+	}
+	if accident, err := s.GetAccidentById(ctx, 7); err != nil || accident.LinkedAccidentID == nil || *accident.LinkedAccidentID != 6 {
+		t.Errorf("Expected NTSB accident 7 linked to accident 6, got %+v, %v", accident, err)
+	}
+	if linked, err := LinkNTSBAccidents(ctx, s.db); err != nil || linked != 0 {
+		t.Errorf("Expected nothing left to link, got %d, %v", linked, err)
+	}
+
+	accidents, total, _, err := s.GetAccidents(ctx, AccidentFilter{Source: SourceFAA}, nil, Pagination{Page: 1, Limit: 10})
+	if err != nil || total != 4 || accidents[0].Source != SourceFAA || accidents[0].LinkedAccidentID != nil {
+		t.Errorf("Expected the 4 FAA accidents, got %v with total %d, %v", accidentIDs(accidents), total, err)
+	}
+}
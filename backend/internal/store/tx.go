@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Tx wraps a database transaction with its dialect.
+// Like DB, queries are written with ? placeholders and rewritten for dialects that number them.
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+// QueryContext executes a query that returns rows.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// QueryRowContext executes a query that is expected to return at most one row.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// ExecContext executes a query without returning any rows.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// PrepareContext creates a prepared statement for use within the transaction.
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return tx.Tx.PrepareContext(ctx, tx.Dialect.Rebind(query))
+}
+
+// PrepareInsertContext prepares an INSERT statement that returns the generated id of each new row.
+func (tx *Tx) PrepareInsertContext(ctx context.Context, query string) (*InsertStmt, error) {
+	stmt, err := tx.PrepareContext(ctx, tx.Dialect.insertQuery(query))
+	if err != nil {
+		return nil, err
+	}
+	return &InsertStmt{stmt: stmt, returning: tx.Dialect.returningID()}, nil
+}
+
+// InsertStmt is a prepared INSERT statement that returns the generated id of each new row.
+type InsertStmt struct {
+	stmt      *sql.Stmt
+	returning bool // Whether the id is read back with RETURNING instead of LastInsertId
+}
+
+// InsertContext executes the statement and returns the generated id of the new row.
+func (s *InsertStmt) InsertContext(ctx context.Context, args ...interface{}) (int, error) {
+	if s.returning {
+		var id int
+		err := s.stmt.QueryRowContext(ctx, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := s.stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Close closes the statement.
+func (s *InsertStmt) Close() error {
+	return s.stmt.Close()
+}
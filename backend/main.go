@@ -20,7 +20,7 @@ func main() {
 	cfg := config.NewConfig()
 
 	// Initialize the database store
-	store, err := store.NewStore(cfg.DataSourceName)
+	store, err := store.NewStore(cfg.DataSourceName, cfg.QueryTimeout)
 	if err != nil {
 		log.Fatalf("Failed to create store: %v", err)
 	}
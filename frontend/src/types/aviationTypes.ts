@@ -20,6 +20,10 @@ export interface Accident {
   location_id?: number;
   remark_text: string;
   injuries?: Injury[];
+  ingested_by?: number;
+  source?: string;
+  source_id?: string;
+  linked_accident_id?: number;
 }
 
 export interface Aircraft {
@@ -28,6 +32,13 @@ export interface Aircraft {
   aircraft_make_name: string;
   aircraft_model_name: string;
   aircraft_operator?: string;
+  year_manufactured?: number;
+  serial_number?: string;
+  engine_type?: string;
+  number_of_seats?: number;
+  aircraft_category?: string;
+  owner_city?: string;
+  owner_state?: string;
 }
 
 export interface Location {
@@ -35,8 +46,9 @@ export interface Location {
   city_name: string;
   state_name: string;
   country_name: string;
-  latitude: number;
-  longitude: number;
+  latitude?: number;
+  longitude?: number;
+  geocode_failed?: boolean;
 }
 
 export interface Injury {